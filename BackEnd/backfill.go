@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"exchangeapp/config"
+	"exchangeapp/services"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runBackfill 导入 CSV / JSON 快照文件到历史表与 Redis 基准 Hash，文件格式见 services.ParseSnapshotFile
+//
+//	exchangeapp backfill [-dry-run] rates-2023.csv rates-2024.json
+func runBackfill(args []string) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only parse and validate the files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: exchangeapp backfill [-dry-run] <file.csv|file.json>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *dryRun {
+		total := 0
+		for _, path := range fs.Args() {
+			snapshots, err := services.ParseSnapshotFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			for _, s := range snapshots {
+				if err := s.ToUSDBase(); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s: %v\n", path, s.UpdatedAt.Format("2006-01-02"), err)
+					return 1
+				}
+			}
+			fmt.Printf("%s: %d snapshots\n", path, len(snapshots))
+			total += len(snapshots)
+		}
+		fmt.Printf("%d snapshots OK\n", total)
+		return 0
+	}
+
+	config.InitConfig()
+	if err := services.InitCurrencyRegistry(); err != nil {
+		fmt.Fprintf(os.Stderr, "Currency registry falls back to embedded dataset: %v\n", err)
+	}
+
+	result, err := services.ImportSnapshotFiles(context.Background(), fs.Args()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Backfill failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported %d snapshots, latest %s", result.Days, result.Latest.Format("2006-01-02"))
+	if result.Activated {
+		fmt.Print(" (now the current snapshot)")
+	}
+	fmt.Println()
+	return 0
+}
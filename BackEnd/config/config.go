@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -22,6 +23,64 @@ type Config struct {
 		Password string `yaml:"password"`
 		DB       int    `yaml:"db"`
 	} `yaml:"redis"`
+	ExchangeRate struct {
+		// 刷新周期：配置了 Cron（5 段表达式，如 "0 16 * * *"）时优先使用，否则按 Interval 固定间隔
+		Interval time.Duration `yaml:"interval"`
+		Cron     string        `yaml:"cron"`
+		// 单个数据源请求的超时时间
+		Timeout time.Duration `yaml:"timeout"`
+		// 整条数据源链都失败时的重试策略（指数退避）
+		Retry struct {
+			MaxAttempts    int           `yaml:"maxAttempts"`
+			InitialBackoff time.Duration `yaml:"initialBackoff"`
+			MaxBackoff     time.Duration `yaml:"maxBackoff"`
+		} `yaml:"retry"`
+		// 新快照的校验规则，不通过的快照进入隔离区等待管理员审核
+		Validation struct {
+			MaxChangePct  float64 `yaml:"maxChangePct"`  // 单个货币相对上一份快照的最大变动（百分比）
+			MinCoverage   float64 `yaml:"minCoverage"`   // 上一份快照中的货币至少有多少比例 (0-1) 出现在新快照中
+			MinCurrencies int     `yaml:"minCurrencies"` // 新快照至少包含的货币数
+			// 上一份快照早于该时长时不再作为覆盖率与跳变的比较基准 (长时间停机、内置或导入的旧快照)
+			MaxBaselineAge time.Duration `yaml:"maxBaselineAge"`
+		} `yaml:"validation"`
+		// 快照抓取后超过该时长未刷新即标记为 stale，但仍继续提供
+		StaleAfter time.Duration `yaml:"staleAfter"`
+		// 按日期查询汇率时当天没有快照的处理方式：previous（取之前最近一天）、interpolate（前后两天线性插值）、error
+		MissingDay string `yaml:"missingDay"`
+		// 每次刷新后预先计算统计指标的货币对，如 "USD/CNY"
+		StatsPairs []string `yaml:"statsPairs"`
+		// 启动时数据源全部不可用且没有任何快照时，从该文件导入内置快照（格式见 services.ParseSnapshotFile），为空则不导入
+		SeedFile string `yaml:"seedFile"`
+		// 按顺序尝试的数据源，前一个失败时自动切换到下一个
+		Providers []RateProviderConfig `yaml:"providers"`
+	} `yaml:"exchangeRate"`
+	// 模拟交易：新用户开户时在 StartingCurrency 钱包中发放 StartingBalance 的虚拟资金
+	PaperTrading struct {
+		StartingCurrency string `yaml:"startingCurrency"`
+		StartingBalance  string `yaml:"startingBalance"` // 十进制字符串
+	} `yaml:"paperTrading"`
+	// 文章全文检索：mysql 使用 FULLTEXT 索引 (ngram 分词，需 MySQL 5.7.6+)，memory 在进程内建立倒排索引
+	Search struct {
+		Engine string `yaml:"engine"`
+	} `yaml:"search"`
+}
+
+// 汇率数据源配置，Type 决定使用哪种实现：
+//   - exchangerate-api: v6.exchangerate-api.com，需要 APIKey，URL 可覆盖默认地址
+//   - file:             本地 JSON 文件，路径为 File
+//   - http-json:        通用 HTTP JSON 接口，字段位置由 BasePath / RatesPath / TimestampPath 指定（点号分隔）
+//   - ecb:              欧洲央行参考汇率 XML，URL / HistoryURL 可覆盖默认的 daily / hist 地址
+type RateProviderConfig struct {
+	Name          string `yaml:"name"`
+	Type          string `yaml:"type"`
+	APIKey        string `yaml:"apiKey"` // 支持 ${ENV} 形式引用环境变量
+	URL           string `yaml:"url"`
+	HistoryURL    string `yaml:"historyUrl"`
+	File          string `yaml:"file"`
+	Base          string `yaml:"base"` // 数据源固定的基准货币，未配置 BasePath 时使用
+	BasePath      string `yaml:"basePath"`
+	RatesPath     string `yaml:"ratesPath"`
+	TimestampPath string `yaml:"timestampPath"`
 }
 
 var AppConfig *Config
@@ -37,6 +96,28 @@ func InitConfig() {
 
 	AppConfig = &Config{}
 
+	// 汇率任务默认值
+	viper.SetDefault("exchangeRate.interval", "24h")
+	viper.SetDefault("exchangeRate.timeout", "10s")
+	viper.SetDefault("exchangeRate.retry.maxAttempts", 3)
+	viper.SetDefault("exchangeRate.retry.initialBackoff", "30s")
+	viper.SetDefault("exchangeRate.retry.maxBackoff", "10m")
+	viper.SetDefault("exchangeRate.validation.maxChangePct", 20)
+	viper.SetDefault("exchangeRate.validation.minCoverage", 0.9)
+	viper.SetDefault("exchangeRate.validation.minCurrencies", 20)
+	viper.SetDefault("exchangeRate.validation.maxBaselineAge", "168h")
+	viper.SetDefault("exchangeRate.missingDay", "previous")
+	viper.SetDefault("exchangeRate.staleAfter", "25h")
+	viper.SetDefault("exchangeRate.statsPairs", []string{"USD/CNY", "EUR/CNY", "JPY/CNY", "GBP/CNY", "EUR/USD", "USD/JPY"})
+	viper.SetDefault("exchangeRate.seedFile", "./config/seed_rates.json")
+
+	// 模拟交易默认值
+	viper.SetDefault("paperTrading.startingCurrency", "USD")
+	viper.SetDefault("paperTrading.startingBalance", "10000")
+
+	// 文章检索默认值
+	viper.SetDefault("search.engine", "mysql")
+
 	if err := viper.Unmarshal(AppConfig); err != nil {
 		log.Fatalf("Unable to decode into struct: %v", err)
 	}
@@ -34,11 +34,24 @@ func InitDB() {
 	if err := global.Db.AutoMigrate(
 		&models.User{},
 		&models.Article{},
+		&models.ArticleCurrency{},
 		&models.Category{},
 		&models.ArticleLike{},
 		&models.Favorite{},
 		&models.Comment{},
 		&models.ExchangeRate{},
+		&models.Currency{},
+		&models.RateAlert{},
+		&models.AlertNotification{},
+		&models.RateQuarantine{},
+		&models.RateOverride{},
+		&models.RateOverrideAudit{},
+		&models.RateSpread{},
+		&models.Watchlist{},
+		&models.WatchlistEntry{},
+		&models.Wallet{},
+		&models.WalletTransaction{},
+		&models.LedgerEntry{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database, got error: %v", err)
 	}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"exchangeapp/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertInput 创建/修改提醒的请求体
+type alertInput struct {
+	FromCurrency    string  `json:"fromCurrency" binding:"required"`
+	ToCurrency      string  `json:"toCurrency" binding:"required"`
+	Condition       string  `json:"condition" binding:"required"` // above | below | change_pct
+	Threshold       float64 `json:"threshold" binding:"required,gt=0"`
+	Recurring       bool    `json:"recurring"`
+	CooldownMinutes int     `json:"cooldownMinutes" binding:"gte=0"`
+	Active          *bool   `json:"active"` // 仅修改时使用，为空表示不变
+}
+
+// validate 校验货币对与条件，返回错误提示
+func (in *alertInput) validate() string {
+	if !services.IsKnownCurrency(in.FromCurrency) || !services.IsKnownCurrency(in.ToCurrency) {
+		return "未知的货币代码"
+	}
+	if in.FromCurrency == in.ToCurrency {
+		return "源货币和目标货币不能相同"
+	}
+	switch in.Condition {
+	case models.AlertAbove, models.AlertBelow, models.AlertChangePct:
+		return ""
+	}
+	return "condition 只支持 above、below、change_pct"
+}
+
+// ======================= 提醒 CRUD =========================
+
+func GetUserAlerts(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	var alerts []models.RateAlert
+	if err := global.Db.Where("user_id = ?", userID).Order("created_at DESC").Find(&alerts).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, alerts)
+}
+
+func CreateAlert(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	var input alertInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := input.validate(); msg != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	alert := models.RateAlert{
+		UserID:          userID,
+		FromCurrency:    input.FromCurrency,
+		ToCurrency:      input.ToCurrency,
+		Condition:       input.Condition,
+		Threshold:       input.Threshold,
+		Recurring:       input.Recurring,
+		CooldownMinutes: input.CooldownMinutes,
+		Active:          true,
+	}
+
+	if err := global.Db.Create(&alert).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, alert)
+}
+
+func UpdateAlert(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	alertID := ctx.Param("id")
+
+	var input alertInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := input.validate(); msg != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// 只能修改自己的提醒
+	var alert models.RateAlert
+	if err := global.Db.Where("id = ? AND user_id = ?", alertID, userID).First(&alert).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "提醒不存在"})
+		return
+	}
+
+	alert.FromCurrency = input.FromCurrency
+	alert.ToCurrency = input.ToCurrency
+	alert.Condition = input.Condition
+	alert.Threshold = input.Threshold
+	alert.Recurring = input.Recurring
+	alert.CooldownMinutes = input.CooldownMinutes
+	if input.Active != nil {
+		alert.Active = *input.Active
+	}
+
+	if err := global.Db.Save(&alert).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, alert)
+}
+
+func DeleteAlert(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	alertID := ctx.Param("id")
+
+	result := global.Db.Where("id = ? AND user_id = ?", alertID, userID).Delete(&models.RateAlert{})
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "提醒不存在"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "提醒已删除"})
+}
+
+// ======================= 通知 =========================
+
+// GetUserNotifications 获取提醒通知，unread=true 时只返回未读
+func GetUserNotifications(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	db := global.Db.Where("user_id = ?", userID)
+	if ctx.Query("unread") == "true" {
+		db = db.Where("`read` = ?", false)
+	}
+
+	var notifications []models.AlertNotification
+	if err := db.Order("created_at DESC").Limit(100).Find(&notifications).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+// MarkNotificationRead 标记通知为已读
+func MarkNotificationRead(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	notificationID := ctx.Param("id")
+
+	result := global.Db.Model(&models.AlertNotification{}).
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		Update("read", true)
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		// MySQL 对值未变化的行不计入 RowsAffected，已读的通知再次标记时需确认通知是否存在
+		var count int64
+		if err := global.Db.Model(&models.AlertNotification{}).
+			Where("id = ? AND user_id = ?", notificationID, userID).
+			Count(&count).Error; err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count == 0 {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "通知不存在"})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已读"})
+}
@@ -6,11 +6,14 @@ import (
 	"errors"
 	"exchangeapp/global"
 	"exchangeapp/models"
+	"exchangeapp/services"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,10 +23,12 @@ import (
 var ctxRedis = context.Background()
 
 const (
-	ArticleListCachePrefix = "articles:list:"   // 分页+分类
-	ArticleSingleCache     = "articles:single:" // 单篇文章详情缓存
-	ArticleHotCache        = "articles:hot"     // 热门文章缓存
-	ArticleViewKey         = "articles:views:"  // 浏览量缓冲池 (Hash or String)
+	ArticleListCachePrefix = "articles:list:"                    // 分页+分类
+	ArticleSingleCache     = "articles:single:"                  // 单篇文章详情缓存
+	ArticleHotCache        = "articles:hot"                      // 热门文章缓存
+	ArticleViewKey         = "articles:views:"                   // 浏览量缓冲池 (Hash or String)
+	ArticleRelatedCache    = ArticleListCachePrefix + "related:" // 货币对相关新闻，随列表缓存一起清理
+	RelatedArticlesLimit   = 5
 	CacheExpire            = 10 * time.Minute
 	UploadDir              = "./uploads/covers"
 )
@@ -39,6 +44,13 @@ type ArticleListResponse struct {
 	Total int64            `json:"total"`
 }
 
+// ArticleDetail 文章详情：保留原始 Content，renderedContent 为展开汇率占位符后的正文
+type ArticleDetail struct {
+	models.Article
+	RenderedContent string                  `json:"renderedContent"`
+	Quotes          []services.ArticleQuote `json:"quotes"`
+}
+
 // ======================= 创建文章 =========================
 
 func CreateArticle(ctx *gin.Context) {
@@ -55,6 +67,14 @@ func CreateArticle(ctx *gin.Context) {
 		return
 	}
 
+	// 货币标签失败不影响发文，可通过管理接口重新识别
+	if err := services.TagArticleCurrencies(global.Db, &req); err != nil {
+		log.Printf("Tagging currencies of article %d failed: %v\n", req.ID, err)
+	}
+	if err := services.ArticleSearch().Index(&req); err != nil {
+		log.Printf("Indexing article %d failed: %v\n", req.ID, err)
+	}
+
 	// 清理相关缓存
 	go clearArticleCache() // 异步清理，不阻塞主请求
 	ctx.JSON(http.StatusCreated, req)
@@ -90,6 +110,13 @@ func UpdateArticle(ctx *gin.Context) {
 		return
 	}
 
+	if err := services.TagArticleCurrencies(global.Db, &article); err != nil {
+		log.Printf("Tagging currencies of article %d failed: %v\n", article.ID, err)
+	}
+	if err := services.ArticleSearch().Index(&article); err != nil {
+		log.Printf("Indexing article %d failed: %v\n", article.ID, err)
+	}
+
 	go clearArticleCacheByID(articleID)
 	ctx.JSON(http.StatusOK, gin.H{"message": "文章已更新", "data": article})
 }
@@ -116,7 +143,12 @@ func DeleteArticle(ctx *gin.Context) {
 			return err
 		}
 
-		// 4. 删除文章本身
+		// 4. 删除文章的货币标签
+		if err := tx.Where("article_id = ?", articleID).Delete(&models.ArticleCurrency{}).Error; err != nil {
+			return err
+		}
+
+		// 5. 删除文章本身
 		if err := tx.Delete(&models.Article{}, articleID).Error; err != nil {
 			return err
 		}
@@ -129,6 +161,12 @@ func DeleteArticle(ctx *gin.Context) {
 		return
 	}
 
+	if id, err := strconv.ParseUint(articleID, 10, 64); err == nil {
+		if err := services.ArticleSearch().Remove(uint(id)); err != nil {
+			log.Printf("Removing article %s from search index failed: %v\n", articleID, err)
+		}
+	}
+
 	// 清理缓存
 	go clearArticleCacheByID(articleID)
 	ctx.JSON(http.StatusOK, gin.H{"message": "文章及其关联数据已删除"})
@@ -140,6 +178,7 @@ func GetArticles(ctx *gin.Context) {
 	pageStr := ctx.DefaultQuery("page", "1") // 获取名为 "page" 的查询参数，如果不存在则返回默认值 "1"
 	limitStr := ctx.DefaultQuery("limit", "10")
 	category := ctx.Query("category")
+	currency := strings.ToUpper(ctx.Query("currency")) // 按自动识别的货币标签筛选
 
 	page, _ := strconv.Atoi(pageStr)
 	limit, _ := strconv.Atoi(limitStr)
@@ -156,6 +195,9 @@ func GetArticles(ctx *gin.Context) {
 		categoryCachePart = "all"
 	}
 	cacheKey := fmt.Sprintf("%s%s:page_%d:limit_%d", ArticleListCachePrefix, categoryCachePart, page, limit)
+	if currency != "" {
+		cacheKey += ":currency_" + currency
+	}
 
 	// 1. 尝试读取缓存
 	if cached, err := global.RedisDB.Get(ctxRedis, cacheKey).Result(); err == nil {
@@ -176,6 +218,9 @@ func GetArticles(ctx *gin.Context) {
 	if category != "" {
 		db = db.Where("category_id = ?", category)
 	}
+	if currency != "" {
+		db = db.Where("id IN (?)", global.Db.Model(&models.ArticleCurrency{}).Select("article_id").Where("currency = ?", currency))
+	}
 
 	// 计算符合当前条件的记录总数（分页功能需要知道总记录数，才能计算总页数）
 	if err := db.Count(&total).Error; err != nil {
@@ -229,6 +274,10 @@ func GetArticleByID(ctx *gin.Context) {
 			}
 			return
 		}
+		// 货币标签随详情一起缓存，更新文章时详情缓存会被清理
+		if codes, err := services.LoadArticleCurrencies(article.ID); err == nil {
+			article.Currencies = codes
+		}
 	}
 
 	// 异步更新浏览量（Fire and Forget）
@@ -258,7 +307,58 @@ func GetArticleByID(ctx *gin.Context) {
 		global.RedisDB.Set(ctxRedis, cacheKey, data, CacheExpire)
 	}
 
-	ctx.JSON(http.StatusOK, article)
+	// 占位符按请求时的汇率展开，不进入详情缓存
+	rendered, quotes := services.RenderArticleContent(ctx.Request.Context(), article.Content, article.CreatedAt)
+	ctx.JSON(http.StatusOK, ArticleDetail{Article: article, RenderedContent: rendered, Quotes: quotes})
+}
+
+// ======================= 货币标签 =========================
+
+// relatedNews 货币对接口附带的相关新闻，失败时返回空列表，不影响汇率数据
+func relatedNews(from, to string) []services.RelatedArticle {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	cacheKey := ArticleRelatedCache + from + "/" + to
+	if cached, err := global.RedisDB.Get(ctxRedis, cacheKey).Result(); err == nil {
+		var related []services.RelatedArticle
+		if json.Unmarshal([]byte(cached), &related) == nil {
+			return related
+		}
+	}
+
+	related, err := services.RelatedArticles(from, to, RelatedArticlesLimit)
+	if err != nil {
+		log.Printf("Loading related articles for %s/%s failed: %v\n", from, to, err)
+		return []services.RelatedArticle{}
+	}
+
+	go func() {
+		data, _ := json.Marshal(related)
+		global.RedisDB.Set(ctxRedis, cacheKey, data, CacheExpire)
+	}()
+	return related
+}
+
+// GetRelatedArticles 货币对相关新闻
+// 参数: pair=USD/CNY 必填
+func GetRelatedArticles(ctx *gin.Context) {
+	from, to, err := services.ParsePair(ctx.Query("pair"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pair 格式应为 USD/CNY"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"pair": from + "/" + to, "related": relatedNews(from, to)})
+}
+
+// RetagArticles 按当前货币注册表重新识别全部文章的货币标签
+func RetagArticles(ctx *gin.Context) {
+	count, err := services.RetagAllArticles()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重新识别货币标签失败: " + err.Error()})
+		return
+	}
+
+	go clearArticleCache()
+	ctx.JSON(http.StatusOK, gin.H{"message": "货币标签已更新", "articles": count})
 }
 
 // ======================= 热门文章缓存 =========================
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"errors"
+	"exchangeapp/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchArticles 全文检索已发布的文章，按相关度排序并返回高亮的标题与摘要片段
+// 参数: q 必填；category 分类 ID；from, to 发布日期 YYYY-MM-DD (含)；page, limit 分页
+func SearchArticles(ctx *gin.Context) {
+	searchArticles(ctx, "published")
+}
+
+// AdminSearchArticles 全文检索全部文章，参数同 SearchArticles，另可用 status 按文章状态过滤，默认不过滤 (仅管理员)
+func AdminSearchArticles(ctx *gin.Context) {
+	searchArticles(ctx, ctx.Query("status"))
+}
+
+func searchArticles(ctx *gin.Context, status string) {
+	query := services.SearchQuery{Query: ctx.Query("q"), Status: status}
+	query.Page, _ = strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	query.Limit, _ = strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+
+	if category := ctx.Query("category"); category != "" {
+		id, err := strconv.ParseUint(category, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "category 必须是分类 ID"})
+			return
+		}
+		query.CategoryID = uint(id)
+	}
+	for _, p := range []struct {
+		key  string
+		dst  *time.Time
+		days int
+	}{{"from", &query.From, 0}, {"to", &query.To, 1}} {
+		if s := ctx.Query(p.key); s != "" {
+			day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": p.key + " 日期格式应为 YYYY-MM-DD"})
+				return
+			}
+			*p.dst = day.AddDate(0, 0, p.days) // to 包含当天，转为次日零点作为上界
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from 不能晚于 to"})
+		return
+	}
+
+	result, err := services.ArticleSearch().Search(ctx.Request.Context(), query)
+	if errors.Is(err, services.ErrEmptySearchQuery) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "参数 q 必填"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "检索失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
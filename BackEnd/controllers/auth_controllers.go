@@ -3,6 +3,7 @@ package controllers
 import (
 	"exchangeapp/global"
 	"exchangeapp/models"
+	"exchangeapp/services"
 	"exchangeapp/utils"
 	"fmt"
 	"net/http"
@@ -162,6 +163,8 @@ func UpdateProfile(ctx *gin.Context) {
 		Email    string `json:"email" binding:"omitempty,email"`
 		Avatar   string `json:"avatar"`
 		Password string `json:"password"`
+		// 模拟交易的本位币
+		HomeCurrency string `json:"homeCurrency"`
 	}
 
 	if err := ctx.ShouldBindJSON(&input); err != nil {
@@ -187,6 +190,14 @@ func UpdateProfile(ctx *gin.Context) {
 		user.Avatar = input.Avatar
 	}
 
+	if input.HomeCurrency != "" {
+		if !services.IsKnownCurrency(input.HomeCurrency) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+			return
+		}
+		user.HomeCurrency = input.HomeCurrency
+	}
+
 	if input.Password != "" {
 		// 校验密码强度
 		if !utils.ValidatePassword(input.Password) {
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"exchangeapp/services"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConvertAmount 金额换算（定点十进制计算，按目标货币最小单位舍入）
+// 参数: from, to, amount 必填；rounding 可选 half-even (默认) / half-up；
+// side 可选 buy (买入 from，按卖出价) / sell (卖出 from，按买入价)，为空时按中间价
+func ConvertAmount(ctx *gin.Context) {
+	from := ctx.Query("from")
+	to := ctx.Query("to")
+	amount := ctx.Query("amount")
+	if from == "" || to == "" || amount == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "参数 from、to 和 amount 必填"})
+		return
+	}
+
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+
+	mode, err := services.ParseRoundingMode(ctx.Query("rounding"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "rounding 只支持 half-even 或 half-up"})
+		return
+	}
+
+	side, err := services.ParseQuoteSide(ctx.Query("side"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "side 只支持 buy 或 sell"})
+		return
+	}
+
+	if _, err := services.ParseDecimal(amount); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "amount 必须是十进制数字，例如 100.25"})
+		return
+	}
+
+	snapshot, err := services.LoadBaseSnapshot(ctx.Request.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrRatesUnavailable) {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取汇率失败"})
+		}
+		return
+	}
+
+	// 生效的管理员人工汇率优先于数据源
+	overrides, err := services.LoadActiveOverrides(time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取人工汇率失败"})
+		return
+	}
+
+	usdToFrom, usdToTo, overridden, ok := overrides.Legs(snapshot, from, to)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不支持的货币对: " + from + "/" + to})
+		return
+	}
+
+	spreads, err := services.LoadSpreads()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取点差配置失败"})
+		return
+	}
+
+	result, err := services.ConvertAmount(from, to, amount, usdToFrom, usdToTo, spreads.For(from, to), side, mode)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "汇率数据异常"})
+		return
+	}
+
+	result.Timestamp = snapshot.FetchedAt
+	result.AsOf = snapshot.AsOf()
+	result.Source = snapshot.Source
+	result.Stale = snapshot.Stale(time.Now())
+	result.Overridden = overridden
+	ctx.JSON(http.StatusOK, result)
+}
+
+// ConvertBatch 批量金额换算
+// 请求体: {"rounding": "half-even", "items": [{"from": "USD", "to": "CNY", "amount": "100", "date": "2024-01-02"}]}
+// date 可选，为空时使用当前快照；每条返回 result 或 error，单条失败不影响整体
+func ConvertBatch(ctx *gin.Context) {
+	var input struct {
+		Rounding string                 `json:"rounding"`
+		Items    []services.ConvertItem `json:"items" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Items) == 0 || len(input.Items) > services.ConvertBatchMaxItems {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("items 数量应在 1 到 %d 之间", services.ConvertBatchMaxItems)})
+		return
+	}
+
+	mode, err := services.ParseRoundingMode(input.Rounding)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "rounding 只支持 half-even 或 half-up"})
+		return
+	}
+
+	results, err := services.ConvertBatch(ctx.Request.Context(), input.Items, mode)
+	if err != nil {
+		if errors.Is(err, services.ErrRatesUnavailable) {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取汇率失败"})
+		}
+		return
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"total":  len(results),
+		"failed": failed,
+		"data":   results,
+	})
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"exchangeapp/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCurrencies 货币列表（代码、名称、符号、小数位），供前端货币选择器使用
+// 参数: includeDeprecated=true 时包含已废止的货币
+func GetCurrencies(ctx *gin.Context) {
+	includeDeprecated := ctx.Query("includeDeprecated") == "true"
+	ctx.JSON(http.StatusOK, services.ListCurrencies(includeDeprecated))
+}
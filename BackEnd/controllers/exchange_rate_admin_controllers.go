@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"exchangeapp/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BackfillExchangeRates 从支持历史数据的数据源（如 ECB）一次性回填历史汇率 (仅管理员)
+func BackfillExchangeRates(ctx *gin.Context) {
+	var input struct {
+		Provider string `json:"provider" binding:"required"` // 数据源名称，对应配置中的 name
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := services.FindHistoricalProvider(input.Provider)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := services.BackfillHistory(ctx.Request.Context(), provider)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "回填失败: " + err.Error(), "days": days})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "历史汇率回填完成",
+		"provider": provider.Name(),
+		"days":     days,
+	})
+}
+
+// RefreshExchangeRates 立即执行一次汇率更新并返回结果 (仅管理员)
+func RefreshExchangeRates(ctx *gin.Context) {
+	result, err := services.RefreshRates(ctx.Request.Context(), services.RefreshTriggerManual)
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshInProgress) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "汇率正在刷新中，请稍后再试"})
+		} else {
+			ctx.JSON(http.StatusBadGateway, gin.H{"error": "刷新失败: " + err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "汇率已刷新", "data": result})
+}
+
+// GetExchangeRateStatus 查看调度状态：上次成功/失败、下次执行时间、数据源 (仅管理员)
+func GetExchangeRateStatus(ctx *gin.Context) {
+	status, err := services.GetSchedulerStatus(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// ======================= 隔离快照审核 =========================
+
+// GetQuarantinedRates 隔离快照列表，status 默认 pending (仅管理员)
+func GetQuarantinedRates(ctx *gin.Context) {
+	status := ctx.DefaultQuery("status", models.QuarantinePending)
+
+	var list []models.RateQuarantine
+	if err := global.Db.Where("status = ?", status).Order("created_at DESC").Limit(100).Find(&list).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// ApproveQuarantinedRates 确认隔离快照无误并使其生效 (仅管理员)
+func ApproveQuarantinedRates(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	q, err := services.ApproveQuarantine(ctx.Request.Context(), uint(id), ctx.GetString("username"))
+	if err != nil {
+		respondQuarantineError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "快照已生效", "data": q})
+}
+
+// RejectQuarantinedRates 驳回隔离快照 (仅管理员)
+func RejectQuarantinedRates(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	q, err := services.RejectQuarantine(uint(id), ctx.GetString("username"))
+	if err != nil {
+		respondQuarantineError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "快照已驳回", "data": q})
+}
+
+func respondQuarantineError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "隔离记录不存在"})
+	case errors.Is(err, services.ErrQuarantineReviewed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "该快照已审核过"})
+	case errors.Is(err, services.ErrRefreshInProgress):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "汇率正在刷新中，请稍后再试"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ======================= 人工汇率 =========================
+
+// GetRateOverrides 人工汇率列表，默认只返回生效中的记录；all=true 时包含已撤销、已过期的 (仅管理员)
+func GetRateOverrides(ctx *gin.Context) {
+	query := global.Db.Order("created_at DESC")
+	if ctx.Query("all") != "true" {
+		query = query.Where("active = ? AND expires_at > ?", true, time.Now())
+	}
+
+	var list []models.RateOverride
+	if err := query.Limit(200).Find(&list).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// CreateRateOverride 为货币对或 USD 基准腿钉住人工汇率，同一货币对已有的人工汇率会被替换 (仅管理员)
+// 请求体: {"from": "USD", "to": "CNY", "rate": 7.2, "reason": "...", "expiresAt": "2024-01-02T00:00:00Z"}
+func CreateRateOverride(ctx *gin.Context) {
+	var input struct {
+		From      string    `json:"from" binding:"required"`
+		To        string    `json:"to" binding:"required"`
+		Rate      float64   `json:"rate" binding:"required,gt=0"`
+		Reason    string    `json:"reason" binding:"required,max=255"`
+		ExpiresAt time.Time `json:"expiresAt" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to := strings.ToUpper(input.From), strings.ToUpper(input.To)
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+	if from == to {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "from 和 to 不能相同"})
+		return
+	}
+	if !input.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt 必须晚于当前时间"})
+		return
+	}
+
+	override := models.RateOverride{
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         input.Rate,
+		Reason:       input.Reason,
+		Author:       ctx.GetString("username"),
+		ExpiresAt:    input.ExpiresAt,
+	}
+	if err := services.CreateRateOverride(&override); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"message": "人工汇率已生效", "data": override})
+}
+
+// RevokeRateOverride 撤销人工汇率，恢复使用数据源 (仅管理员)
+func RevokeRateOverride(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	override, err := services.RevokeRateOverride(uint(id), ctx.GetString("username"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "人工汇率不存在"})
+		case errors.Is(err, services.ErrOverrideInactive):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "该人工汇率已撤销"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "人工汇率已撤销", "data": override})
+}
+
+// GetRateOverrideAudits 人工汇率审计记录，可按 overrideId 过滤 (仅管理员)
+func GetRateOverrideAudits(ctx *gin.Context) {
+	query := global.Db.Order("created_at DESC")
+	if id := ctx.Query("overrideId"); id != "" {
+		query = query.Where("override_id = ?", id)
+	}
+
+	var list []models.RateOverrideAudit
+	if err := query.Limit(200).Find(&list).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// ======================= 买卖点差 =========================
+
+// GetRateSpreads 点差配置列表 (仅管理员)
+func GetRateSpreads(ctx *gin.Context) {
+	var list []models.RateSpread
+	if err := global.Db.Order("scope, code").Find(&list).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// SaveRateSpread 新增或更新点差，(scope, code) 已存在时覆盖 (仅管理员)
+// 请求体: {"scope": "global|currency|pair", "code": "JPY" 或 "USD/JPY", "value": 50, "unit": "bps|pct"}
+func SaveRateSpread(ctx *gin.Context) {
+	var input struct {
+		Scope string   `json:"scope" binding:"required"`
+		Code  string   `json:"code"`
+		Value *float64 `json:"value" binding:"required"`
+		Unit  string   `json:"unit" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spread := models.RateSpread{
+		Scope:     input.Scope,
+		Code:      input.Code,
+		Value:     *input.Value,
+		Unit:      input.Unit,
+		UpdatedBy: ctx.GetString("username"),
+	}
+	if err := services.ValidateSpread(&spread); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.SaveSpread(&spread); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "点差已保存", "data": spread})
+}
+
+// DeleteRateSpread 删除点差配置，该范围回退到下一级配置 (仅管理员)
+func DeleteRateSpread(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	if err := services.DeleteSpread(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "点差配置不存在"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "点差已删除"})
+}
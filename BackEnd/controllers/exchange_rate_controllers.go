@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"context"
-	"exchangeapp/global"
+	"errors"
 	"exchangeapp/models"
+	"exchangeapp/services"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,35 +11,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	// 对应 Scheduler 中的 Key
-	ExchangeRateRedisKey = "rates:usd_base"
-)
-
-// CreateExchangeRate 手动创建汇率 (仅用于测试或特殊补录)
-func CreateExchangeRate(ctx *gin.Context) {
-	var exchangeRate models.ExchangeRate
-	if err := ctx.ShouldBindJSON(&exchangeRate); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	exchangeRate.Date = time.Now()
-
-	if err := global.Db.Create(&exchangeRate).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// 注意：手动创建的汇率现在不会自动更新到 Redis 基准 Hash 中，
-	// 除非它是 USD -> X 的汇率。这里仅做简单的单条缓存兼容。
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message": "汇率记录已创建 (注意：可能不影响实时计算逻辑)",
-		"data":    exchangeRate,
-	})
+// LatestRate 最新汇率，附带提到该货币对的相关新闻
+type LatestRate struct {
+	models.ExchangeRate
+	Related []services.RelatedArticle `json:"related"`
 }
 
 // GetLatestRate 获取最新汇率 (核心计算逻辑)
-// 逻辑：利用 Redis 中的 USD 基准汇率进行实时换算
+// 逻辑：利用 Redis 中的 USD 基准汇率进行实时换算，生效的管理员人工汇率优先于数据源
 func GetLatestRate(ctx *gin.Context) {
 	from := ctx.Query("from")
 	to := ctx.Query("to")
@@ -49,103 +28,367 @@ func GetLatestRate(ctx *gin.Context) {
 		return
 	}
 
+	// 货币代码必须在注册表中
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+
 	// 1. 同币种直接返回
 	if from == to {
-		ctx.JSON(http.StatusOK, models.ExchangeRate{
-			FromCurrency: from, ToCurrency: to, Rate: 1, Date: time.Now(),
+		ctx.JSON(http.StatusOK, LatestRate{
+			ExchangeRate: models.ExchangeRate{
+				FromCurrency: from, ToCurrency: to, Rate: 1, Mid: 1, Bid: 1, Ask: 1, Date: time.Now(),
+			},
+			Related: relatedNews(from, to),
 		})
 		return
 	}
 
-	// 2. 从 Redis Hash 中获取所有基准汇率
-	// 这是一个 O(1) 操作，虽然取回了所有 160 个字段，但数据量很小 (几KB)，速度极快
-	// 如果追求极致，可以使用 HMGet 只取 from 和 to 两个字段
-	allRates, err := global.RedisDB.HGetAll(context.Background(), ExchangeRateRedisKey).Result()
-
-	// 检查 Redis 是否有数据
-	if err != nil || len(allRates) == 0 {
-		// Redis 挂了或没数据，降级查 DB (尝试直接查库)
-		var rate models.ExchangeRate
-		if err := global.Db.Where("from_currency = ? AND to_currency = ?", from, to).Order("date desc").First(&rate).Error; err != nil {
-			// 如果 DB 也只有 USD 基准，这里需要复杂的 SQL 自连接查询，或者直接报错
-			// 为了简单，这里尝试找 USD 中转
-			// 这是一个兜底策略，实际生产环境 Redis 不应该空
-			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
-			return
-		}
-		ctx.JSON(http.StatusOK, rate)
+	// 2. 只用 HMGET 取 from 和 to 两条 USD 基准腿，Redis 没数据时降级查库
+	snapshot, err := services.LoadBaseRatesFor(ctx.Request.Context(), []string{from, to})
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
 		return
 	}
 
-	// 3. 解析汇率值
-	// 我们需要: Rate(USD->From) 和 Rate(USD->To)
-	// 如果 from 是 USD，Rate(USD->From) = 1
-	rateUSDToFrom := 1.0
-	rateUSDToTo := 1.0
-
-	if from != "USD" {
-		valStr, ok := allRates[from]
-		if !ok {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "不支持的源货币: " + from})
-			return
-		}
-		rateUSDToFrom, _ = strconv.ParseFloat(valStr, 64)
+	overrides, err := services.LoadActiveOverrides(time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取人工汇率失败"})
+		return
 	}
 
-	if to != "USD" {
-		valStr, ok := allRates[to]
-		if !ok {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "不支持的目标货币: " + to})
-			return
-		}
-		rateUSDToTo, _ = strconv.ParseFloat(valStr, 64)
+	// 3. 人工汇率优先，其次数据源
+	rateUSDToFrom, rateUSDToTo, overridden, ok := overrides.Legs(snapshot, from, to)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不支持的货币对: " + from + "/" + to})
+		return
 	}
 
 	// 4. 计算交叉汇率
 	// 公式: Rate(From -> To) = Rate(USD -> To) / Rate(USD -> From)
-	if rateUSDToFrom == 0 {
+	finalRate, err := services.CrossRate(rateUSDToFrom, rateUSDToTo)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "汇率数据异常"})
 		return
 	}
-	finalRate := rateUSDToTo / rateUSDToFrom
 
-	// 5. 构造返回结果
-	response := models.ExchangeRate{
-		FromCurrency: from,
-		ToCurrency:   to,
-		Rate:         finalRate,
-		Date:         time.Now(), // 这里其实应该取 Redis 里的更新时间，为了简化直接用 Now
+	// 5. 按点差展开买入价 / 卖出价
+	spreads, err := services.LoadSpreads()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取点差配置失败"})
+		return
+	}
+	bid, ask := spreads.For(from, to).Quote(finalRate)
+
+	// 6. 构造返回结果，标明数据实际时间以及是否过期 (刷新失败时继续提供最后一份快照)
+	asOf := snapshot.AsOf()
+	response := LatestRate{
+		ExchangeRate: models.ExchangeRate{
+			FromCurrency: from,
+			ToCurrency:   to,
+			Rate:         finalRate,
+			Mid:          finalRate,
+			Bid:          bid,
+			Ask:          ask,
+			Date:         snapshot.AsOf(),
+			Source:       snapshot.Source,
+			Overridden:   overridden,
+			AsOf:         &asOf,
+			Stale:        snapshot.Stale(time.Now()),
+		},
+		Related: relatedNews(from, to),
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
 
 // 加一个专门返回所有 USD 基准汇率的接口，供前端初始化列表使用
+// 优先从 Redis 获取，没数据时取数据库中最新一天的快照；生效的 USD 基准腿人工汇率会覆盖数据源的值
 func GetBaseRates(ctx *gin.Context) {
-	// 1. 优先从 Redis 获取
-	allRates, err := global.RedisDB.HGetAll(context.Background(), ExchangeRateRedisKey).Result()
-
-	if err == nil && len(allRates) > 0 {
-		var ratesList []models.ExchangeRate
-		for currency, rateStr := range allRates {
-			rate, _ := strconv.ParseFloat(rateStr, 64)
-			ratesList = append(ratesList, models.ExchangeRate{
-				FromCurrency: "USD",
-				ToCurrency:   currency,
-				Rate:         rate,
-				Date:         time.Now(),
-			})
+	snapshot, err := services.LoadBaseSnapshot(ctx.Request.Context())
+	if errors.Is(err, services.ErrRatesUnavailable) {
+		snapshot = &services.BaseSnapshot{} // 还没有任何快照时只返回人工汇率
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取汇率列表失败"})
+		return
+	}
+
+	overrides, err := services.LoadActiveOverrides(time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取人工汇率失败"})
+		return
+	}
+
+	spreads, err := services.LoadSpreads()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取点差配置失败"})
+		return
+	}
+
+	asOf := snapshot.AsOf()
+	stale := snapshot.Stale(time.Now())
+	ratesList := make([]models.ExchangeRate, 0, len(snapshot.Rates))
+	for _, currency := range services.ListCurrencies(true) {
+		// 数据源偶尔会返回注册表中没有的代码，这里只展示注册表中的货币
+		rate, overridden := overrides.USDRate(currency.Code)
+		if !overridden {
+			var ok bool
+			if rate, ok = snapshot.Rates[currency.Code]; !ok {
+				continue
+			}
 		}
-		ctx.JSON(http.StatusOK, ratesList)
+		bid, ask := spreads.For("USD", currency.Code).Quote(rate)
+		ratesList = append(ratesList, models.ExchangeRate{
+			FromCurrency: "USD",
+			ToCurrency:   currency.Code,
+			Rate:         rate,
+			Mid:          rate,
+			Bid:          bid,
+			Ask:          ask,
+			Date:         asOf,
+			Source:       snapshot.Source,
+			Overridden:   overridden,
+			AsOf:         &asOf,
+			Stale:        stale,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, ratesList)
+}
+
+// GetExchangeRateAt 按日期查询交叉汇率 (用于按记账日重新估值)
+// 参数: from, to, date=YYYY-MM-DD 必填；missing=previous|interpolate|error 可选，默认取配置 exchangeRate.missingDay
+func GetExchangeRateAt(ctx *gin.Context) {
+	from := ctx.Query("from")
+	to := ctx.Query("to")
+	dateStr := ctx.Query("date")
+	if from == "" || to == "" || dateStr == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "参数 from、to 和 date 必填"})
 		return
 	}
 
-	// 2. Redis 没数据，查数据库 (查所有 USD 开头的)
-	var rates []models.ExchangeRate
-	if err := global.Db.Where("from_currency = ?", "USD").Find(&rates).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取汇率列表失败"})
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "date 日期格式应为 YYYY-MM-DD"})
+		return
+	}
+
+	mode, err := services.ParseMissingDayMode(ctx.Query("missing"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing 只支持 previous、interpolate、error"})
+		return
+	}
+
+	snapshot, err := services.LoadSnapshotOn(date, mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoSnapshotOnDate):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "该日期没有汇率快照"})
+		case errors.Is(err, services.ErrRatesUnavailable):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "该日期之前没有汇率数据"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取历史汇率失败"})
+		}
+		return
+	}
+
+	usdToFrom, okFrom := snapshot.USDRate(from)
+	usdToTo, okTo := snapshot.USDRate(to)
+	if !okFrom || !okTo {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "该日期的快照中没有此货币对"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"from":          from,
+		"to":            to,
+		"rate":          usdToTo / usdToFrom,
+		"date":          snapshot.Date.Format("2006-01-02"),
+		"effectiveDate": snapshot.EffectiveDate.Format("2006-01-02"),
+		"method":        snapshot.Method,
+		"missing":       snapshot.Mode,
+		"source":        snapshot.Source,
+	})
+}
+
+// GetExchangeRateMatrix 交叉汇率矩阵
+// 参数: codes=USD,EUR,CNY 必填；inverse=true 时返回反向视图 (1 列货币 = ? 行货币)
+// 只用一次 Redis 往返 (HMGET) 取出所需的 USD 基准汇率，替代前端 N² 次调用 /exchangeRates/latest
+func GetExchangeRateMatrix(ctx *gin.Context) {
+	codes, err := services.ParseCurrencyCodes(ctx.Query("codes"), services.MatrixMaxCodes)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "codes 参数无效: " + err.Error()})
+		return
+	}
+
+	inverse, err := strconv.ParseBool(ctx.DefaultQuery("inverse", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "inverse 应为 true 或 false"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, rates)
+	snapshot, err := services.LoadBaseRatesFor(ctx.Request.Context(), codes)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
+		return
+	}
+
+	overrides, err := services.LoadActiveOverrides(time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "读取人工汇率失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"source":    snapshot.Source,
+		"fetchedAt": snapshot.FetchedAt,
+		"asOf":      snapshot.AsOf(),
+		"stale":     snapshot.Stale(time.Now()),
+		"matrix":    services.BuildRateMatrix(snapshot, overrides, codes, inverse),
+	})
+}
+
+// GetExchangeRateHistory 获取某货币对的历史汇率序列
+// 参数: from, to 必填；start, end 格式 YYYY-MM-DD，默认最近 30 天
+func GetExchangeRateHistory(ctx *gin.Context) {
+	from := ctx.Query("from")
+	to := ctx.Query("to")
+	if from == "" || to == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "参数 from 和 to 必填"})
+		return
+	}
+
+	start, end, ok := parseDateRange(ctx, "start", "end", 30)
+	if !ok {
+		return
+	}
+
+	points, err := services.GetCrossRateHistory(from, to, start, end)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取历史汇率失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"to":      to,
+		"start":   start.Format("2006-01-02"),
+		"end":     end.Format("2006-01-02"),
+		"data":    points,
+		"related": relatedNews(from, to),
+	})
+}
+
+// GetExchangeRateCandles 获取货币对 K 线
+// 参数: pair=USD/CNY 必填；interval=1d|1w|1M (默认 1d)；from, to 格式 YYYY-MM-DD，默认最近一年；
+// points=N 时使用 LTTB 降采样到最多 N 根
+func GetExchangeRateCandles(ctx *gin.Context) {
+	from, to, err := services.ParsePair(ctx.Query("pair"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pair 格式应为 USD/CNY"})
+		return
+	}
+
+	interval := ctx.DefaultQuery("interval", "1d")
+	if interval != "1d" && interval != "1w" && interval != "1M" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "interval 只支持 1d、1w、1M"})
+		return
+	}
+
+	points := 0
+	if pointsStr := ctx.Query("points"); pointsStr != "" {
+		points, err = strconv.Atoi(pointsStr)
+		if err != nil || points < 3 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "points 必须是不小于 3 的整数"})
+			return
+		}
+	}
+
+	start, end, ok := parseDateRange(ctx, "from", "to", 365)
+	if !ok {
+		return
+	}
+
+	candles, err := services.GetCandles(ctx.Request.Context(), from, to, interval, start, end, points)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取 K 线失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"pair":     from + "/" + to,
+		"interval": interval,
+		"from":     start.Format("2006-01-02"),
+		"to":       end.Format("2006-01-02"),
+		"data":     candles,
+		"related":  relatedNews(from, to),
+	})
+}
+
+// GetExchangeRateStats 货币对统计指标：1d/7d/30d 涨跌、SMA/EMA、最高最低、年化波动率
+// 参数: pair=USD/CNY 必填；window=30d (2d-365d，默认 30d)
+func GetExchangeRateStats(ctx *gin.Context) {
+	from, to, err := services.ParsePair(ctx.Query("pair"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pair 格式应为 USD/CNY"})
+		return
+	}
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+
+	window, err := services.ParseStatsWindow(ctx.Query("window"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "window 应为 2d 到 365d 之间，例如 30d"})
+		return
+	}
+
+	stats, err := services.GetRateStats(ctx.Request.Context(), from, to, window)
+	if err != nil {
+		if errors.Is(err, services.ErrRatesUnavailable) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "该货币对没有历史数据"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "计算统计指标失败"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
+
+// parseDateRange 解析 YYYY-MM-DD 格式的日期区间，结束日期默认今天，开始日期默认往前 defaultDays 天
+// 解析失败时已写入 400 响应并返回 false
+func parseDateRange(ctx *gin.Context, startKey, endKey string, defaultDays int) (time.Time, time.Time, bool) {
+	end := time.Now()
+	if endStr := ctx.Query(endKey); endStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", endStr, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": endKey + " 日期格式应为 YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		end = t
+	}
+
+	start := end.AddDate(0, 0, -defaultDays)
+	if startStr := ctx.Query(startKey); startStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", startStr, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": startKey + " 日期格式应为 YYYY-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		start = t
+	}
+
+	if start.After(end) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": startKey + " 不能晚于 " + endKey})
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
 }
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"exchangeapp/services"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每写出多少天推送一次数据到客户端
+const exportFlushEvery = 100
+
+// ExportExchangeRates 导出历史汇率，边查询边写出，不在内存中缓存整个区间
+// 参数: pairs=USD/CNY,EUR/CNY 必填；from, to 格式 YYYY-MM-DD，默认最近一年；
+// format=csv|xlsx|json (默认 csv)；layout=wide|long (默认 wide)；precision 小数位数 (默认 6)；
+// locale=de|fr|zh... 时 CSV 按该地区格式输出数字 (千分位、小数点，小数点为逗号时用分号分隔字段)，
+// XLSX 写入数字单元格由 Excel 按本地设置显示，JSON 始终输出数字
+func ExportExchangeRates(ctx *gin.Context) {
+	pairs, err := services.ParseExportPairs(ctx.Query("pairs"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pairs 参数无效: " + err.Error()})
+		return
+	}
+
+	start, end, ok := parseDateRange(ctx, "from", "to", 365)
+	if !ok {
+		return
+	}
+
+	opts := services.ExportOptions{
+		Format:    ctx.Query("format"),
+		Layout:    ctx.Query("layout"),
+		Locale:    ctx.Query("locale"),
+		Precision: services.DefaultExportPrecision,
+	}
+	if p := ctx.Query("precision"); p != "" {
+		if opts.Precision, err = strconv.Atoi(p); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "precision 必须是整数"})
+			return
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "导出参数无效: " + err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("exchange-rates_%s_%s.%s", start.Format("20060102"), end.Format("20060102"), opts.Format)
+	ctx.Header("Content-Type", opts.ContentType())
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+	ctx.Status(http.StatusOK)
+
+	// 响应头已发出，之后的错误只能记录日志并截断输出
+	exporter, err := services.NewRateExporter(ctx.Writer, opts, pairs)
+	if err != nil {
+		log.Printf("Export exchange rates failed: %v\n", err)
+		return
+	}
+
+	days := 0
+	err = services.StreamRateHistory(ctx.Request.Context(), pairs, start, end, func(day time.Time, rates []*float64) error {
+		if err := exporter.WriteDay(day, rates); err != nil {
+			return err
+		}
+		if days++; days%exportFlushEvery == 0 {
+			if err := exporter.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Export exchange rates failed after %d days: %v\n", days, err)
+		return
+	}
+	if err := exporter.Close(); err != nil {
+		log.Printf("Export exchange rates failed: %v\n", err)
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"exchangeapp/services"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const StreamHeartbeat = 30 * time.Second
+
+// parseStreamPairs 解析 pairs=USD/CNY,EUR/JPY，为空表示订阅全部 USD 基准汇率
+func parseStreamPairs(ctx *gin.Context) []string {
+	var pairs []string
+	for _, p := range strings.Split(ctx.Query("pairs"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// StreamExchangeRates 通过 Server-Sent Events 推送汇率变化
+// 连接建立时先发送一次 snapshot 事件，之后每次调度器写入新快照发送 rates 事件（仅含变化的货币对）
+func StreamExchangeRates(ctx *gin.Context) {
+	sub, err := services.SubscribeRates(parseStreamPairs(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pairs 格式应为 USD/CNY,EUR/JPY"})
+		return
+	}
+	defer services.UnsubscribeRates(sub)
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+
+	if snapshot, err := services.LoadBaseSnapshot(ctx.Request.Context()); err == nil {
+		ctx.SSEvent("snapshot", sub.Snapshot(snapshot))
+		ctx.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case diff := <-sub.C:
+			ctx.SSEvent("rates", diff)
+			return true
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// StreamExchangeRatesWS WebSocket 版本的汇率推送，消息格式与 SSE 一致: {"event": "...", "data": {...}}
+func StreamExchangeRatesWS(ctx *gin.Context) {
+	sub, err := services.SubscribeRates(parseStreamPairs(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pairs 格式应为 USD/CNY,EUR/JPY"})
+		return
+	}
+	// 握手失败时 Handler 不会被调用，订阅需在外层释放
+	defer services.UnsubscribeRates(sub)
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		// 客户端不需要发送消息，读循环只用于感知连接关闭
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var msg string
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+		}()
+
+		if snapshot, err := services.LoadBaseSnapshot(ctx.Request.Context()); err == nil {
+			if websocket.JSON.Send(ws, gin.H{"event": "snapshot", "data": sub.Snapshot(snapshot)}) != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(StreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			var msg gin.H
+			select {
+			case diff := <-sub.C:
+				msg = gin.H{"event": "rates", "data": diff}
+			case <-heartbeat.C:
+				msg = gin.H{"event": "ping", "data": time.Now().Unix()}
+			case <-closed:
+				return
+			}
+			if websocket.JSON.Send(ws, msg) != nil {
+				return
+			}
+		}
+	}).ServeHTTP(ctx.Writer, ctx.Request)
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"exchangeapp/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader 兑换请求必须携带的幂等键请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// GetWallets 模拟交易钱包及按本位币的估值、整体盈亏
+// 参数: home 可选，默认使用用户资料中的本位币
+func GetWallets(ctx *gin.Context) {
+	home, ok := resolveHomeCurrency(ctx)
+	if !ok {
+		return
+	}
+
+	portfolio, err := services.GetPortfolio(ctx.Request.Context(), ctx.GetUint("userID"), home)
+	if err != nil {
+		respondWalletError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, portfolio)
+}
+
+// ExchangeWallet 按当前报价 (含点差) 卖出 from 换成 to
+// 请求头 Idempotency-Key 必填，同一个键重复提交返回同一笔交易
+// 请求体: {"from": "USD", "to": "JPY", "amount": "100.00"}
+func ExchangeWallet(ctx *gin.Context) {
+	key := strings.TrimSpace(ctx.GetHeader(IdempotencyKeyHeader))
+	if key == "" || len(key) > 64 || key == services.InitialDepositKey {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求头 Idempotency-Key 必填且不超过 64 个字符"})
+		return
+	}
+
+	var input struct {
+		From   string `json:"from" binding:"required"`
+		To     string `json:"to" binding:"required"`
+		Amount string `json:"amount" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to := strings.ToUpper(input.From), strings.ToUpper(input.To)
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+	if from == to {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "源货币和目标货币不能相同"})
+		return
+	}
+
+	record, replayed, err := services.ExecuteExchange(ctx.Request.Context(), ctx.GetUint("userID"), services.ExchangeRequest{
+		From:           from,
+		To:             to,
+		Amount:         input.Amount,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		respondWalletError(ctx, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if replayed {
+		status = http.StatusOK
+	}
+	ctx.JSON(status, gin.H{"replayed": replayed, "data": record})
+}
+
+// GetWalletTransactions 交易记录 (含复式分录)，兑换记录附带按本位币计算的盈亏
+// 参数: home 可选；page 默认 1，limit 默认 20
+func GetWalletTransactions(ctx *gin.Context) {
+	home, ok := resolveHomeCurrency(ctx)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	records, total, err := services.GetTransactionHistory(ctx.Request.Context(), ctx.GetUint("userID"), home, page, limit)
+	if err != nil {
+		respondWalletError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"homeCurrency": home,
+		"total":        total,
+		"data":         records,
+	})
+}
+
+// resolveHomeCurrency 本位币：查询参数 home 优先，其次用户资料中的设置，默认 USD
+// 校验失败时已写入 400 响应并返回 false
+func resolveHomeCurrency(ctx *gin.Context) (string, bool) {
+	home := strings.ToUpper(ctx.Query("home"))
+	if home == "" {
+		var user models.User
+		if err := global.Db.Select("home_currency").First(&user, ctx.GetUint("userID")).Error; err == nil {
+			home = user.HomeCurrency
+		}
+	}
+	if home == "" {
+		home = "USD"
+	}
+
+	if !services.IsKnownCurrency(home) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的本位币: " + home})
+		return "", false
+	}
+	return home, true
+}
+
+func respondWalletError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInsufficientFunds):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "余额不足"})
+	case errors.Is(err, services.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "金额无效: " + err.Error()})
+	case errors.Is(err, services.ErrIdempotencyConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key 已用于另一笔不同的交易"})
+	case errors.Is(err, services.ErrQuoteStale):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "汇率数据已过期，暂停交易"})
+	case errors.Is(err, services.ErrRatesUnavailable):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"exchangeapp/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWatchlist 获取自选货币对列表 (按用户排序)
+func GetWatchlist(ctx *gin.Context) {
+	list, err := services.GetWatchlist(ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// AddWatchlistEntry 添加自选货币对，追加到列表末尾
+// 请求体: {"pair": "USD/CNY"}
+func AddWatchlistEntry(ctx *gin.Context) {
+	var input struct {
+		Pair string `json:"pair" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := services.ParsePair(strings.TrimSpace(input.Pair))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pair 格式应为 USD/CNY"})
+		return
+	}
+	if !services.IsKnownCurrency(from) || !services.IsKnownCurrency(to) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的货币代码"})
+		return
+	}
+	if from == to {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "源货币和目标货币不能相同"})
+		return
+	}
+
+	entry, err := services.AddWatchlistEntry(ctx.GetUint("userID"), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWatchlistDuplicate):
+			ctx.JSON(http.StatusConflict, gin.H{"error": "该货币对已在自选中"})
+		case errors.Is(err, services.ErrWatchlistFull):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "自选最多 " + strconv.Itoa(services.WatchlistMaxEntries) + " 个货币对"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, entry)
+}
+
+// ReorderWatchlist 调整自选顺序
+// 请求体: {"ids": [3, 1, 2]}，必须包含全部条目
+func ReorderWatchlist(ctx *gin.Context) {
+	var input struct {
+		IDs []uint `json:"ids" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := services.ReorderWatchlist(ctx.GetUint("userID"), input.IDs)
+	if err != nil {
+		if errors.Is(err, services.ErrWatchlistOrder) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "ids 必须恰好包含全部自选条目"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// DeleteWatchlistEntry 删除自选货币对
+func DeleteWatchlistEntry(ctx *gin.Context) {
+	entryID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	found, err := services.RemoveWatchlistEntry(ctx.GetUint("userID"), uint(entryID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "自选条目不存在"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已从自选中移除"})
+}
+
+// GetWatchlistQuotes 一次性返回全部自选货币对的当前汇率、24 小时与 7 天涨跌幅
+func GetWatchlistQuotes(ctx *gin.Context) {
+	list, err := services.GetWatchlist(ctx.GetUint("userID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	quotes, err := services.WatchlistQuotes(ctx.Request.Context(), list.Entries, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrRatesUnavailable) {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时汇率暂时不可用"})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quotes)
+}
@@ -15,9 +15,24 @@ import (
 )
 
 func main() {
+	// 子命令：从快照文件导入历史汇率后退出，见 backfill.go
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		os.Exit(runBackfill(os.Args[2:]))
+	}
+
 	// 1. 初始化配置
 	config.InitConfig()
 
+	// 货币注册表：将内置数据集写入数据库并加载
+	if err := services.InitCurrencyRegistry(); err != nil {
+		log.Printf("Currency registry falls back to embedded dataset: %v\n", err)
+	}
+
+	// 文章全文检索
+	if err := services.InitSearchIndex(); err != nil {
+		log.Printf("Initializing article search index failed: %v\n", err)
+	}
+
 	// 2. 创建全局 Context 用于优雅控制后台任务
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // 确保 main 退出时 context 被取消
@@ -25,6 +40,9 @@ func main() {
 	// 3. 启动汇率定时任务 (传入 context)
 	services.StartExchangeRateScheduler(ctx)
 
+	// 订阅汇率更新频道，向本机的 SSE / WebSocket 连接推送
+	services.StartRateHub(ctx)
+
 	// 4. 路由与服务器配置
 	r := router.SetupRouter()
 	port := resolvePort(config.AppConfig.App.Port)
@@ -21,4 +21,6 @@ type Article struct {
 	AuthorID   uint   `json:"authorId"`             // 创建者（通常为 admin）
 	CategoryID uint   `json:"categoryId,omitempty"` // 分类
 	Status     string `gorm:"default:'published'" json:"status"`
+
+	Currencies []string `gorm:"-" json:"currencies,omitempty"` // 自动识别的货币标签，见 ArticleCurrency
 }
@@ -0,0 +1,8 @@
+package models
+
+// ArticleCurrency 文章提到的货币，保存文章时根据标题、摘要和正文自动识别
+type ArticleCurrency struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	ArticleID uint   `gorm:"uniqueIndex:idx_article_currency,priority:1;not null" json:"articleId"`
+	Currency  string `gorm:"size:10;uniqueIndex:idx_article_currency,priority:2;index;not null" json:"currency"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// Currency ISO 4217 货币信息，启动时从内置数据集初始化
+type Currency struct {
+	Code       string `gorm:"primaryKey;size:3" json:"code"`
+	Numeric    string `gorm:"size:3" json:"numeric"` // ISO 数字代码，非 ISO 货币（如 GGP）为空
+	NameEn     string `json:"nameEn"`
+	NameZh     string `json:"nameZh"`
+	Symbol     string `json:"symbol"`
+	MinorUnits int    `json:"minorUnits"`
+	Deprecated bool   `gorm:"default:false" json:"deprecated"` // 已被替代或停止流通
+}
@@ -2,10 +2,22 @@ package models
 
 import "time"
 
+// ExchangeRate 每日汇率快照，(from, to, date) 唯一，同一天重复刷新会覆盖当天的值
 type ExchangeRate struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
-	FromCurrency string    `json:"fromCurrency" binding:"required"`
-	ToCurrency   string    `json:"toCurrency" binding:"required"`
+	FromCurrency string    `gorm:"size:10;uniqueIndex:idx_rate_from_to_date,priority:1" json:"fromCurrency" binding:"required"`
+	ToCurrency   string    `gorm:"size:10;uniqueIndex:idx_rate_from_to_date,priority:2" json:"toCurrency" binding:"required"`
 	Rate         float64   `json:"rate" binding:"required"`
-	Date         time.Time `json:"date"`
+	Date         time.Time `gorm:"type:date;uniqueIndex:idx_rate_from_to_date,priority:3" json:"date"`
+	Source       string    `gorm:"size:64" json:"source,omitempty"` // 提供该快照的数据源
+	// 数据源声明的更新时间 (如 time_last_update) 与本服务抓取的时间
+	ProviderUpdatedAt *time.Time `json:"providerUpdatedAt,omitempty"`
+	FetchedAt         *time.Time `json:"fetchedAt,omitempty"`
+	// 以下字段只出现在实时报价响应中：Mid 与 Rate 相同为中间价，Bid / Ask 为按点差展开的买入价 / 卖出价
+	Mid        float64    `gorm:"-" json:"mid,omitempty"`
+	Bid        float64    `gorm:"-" json:"bid,omitempty"`
+	Ask        float64    `gorm:"-" json:"ask,omitempty"`
+	Overridden bool       `gorm:"-" json:"overridden,omitempty"` // 结果受管理员人工汇率影响
+	AsOf       *time.Time `gorm:"-" json:"asOf,omitempty"`       // 数据实际对应的时间
+	Stale      bool       `gorm:"-" json:"stale"`                // 快照超过 staleAfter 未刷新
 }
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 提醒条件
+const (
+	AlertAbove     = "above"      // 汇率高于阈值
+	AlertBelow     = "below"      // 汇率低于阈值
+	AlertChangePct = "change_pct" // 相比前一天的涨跌幅（绝对值）超过阈值，阈值单位为百分比
+)
+
+// RateAlert 用户设置的汇率提醒
+type RateAlert struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID          uint       `gorm:"index;not null" json:"userId"`
+	FromCurrency    string     `gorm:"size:10;not null" json:"fromCurrency"`
+	ToCurrency      string     `gorm:"size:10;not null" json:"toCurrency"`
+	Condition       string     `gorm:"size:20;not null" json:"condition"`
+	Threshold       float64    `json:"threshold"`
+	Recurring       bool       `json:"recurring"`       // false 表示触发一次后自动停用
+	CooldownMinutes int        `json:"cooldownMinutes"` // 两次触发之间的最小间隔
+	Active          bool       `gorm:"index" json:"active"`
+	LastTriggeredAt *time.Time `json:"lastTriggeredAt,omitempty"`
+}
+
+// AlertNotification 提醒触发后写给用户的通知
+type AlertNotification struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	UserID  uint    `gorm:"index;not null" json:"userId"`
+	AlertID uint    `gorm:"index" json:"alertId"`
+	Message string  `json:"message"`
+	Rate    float64 `json:"rate"`
+	Read    bool    `gorm:"index" json:"read"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 人工汇率的审计动作
+const (
+	OverrideActionCreate  = "create"
+	OverrideActionReplace = "replace" // 同一货币对创建新的人工汇率时，旧记录被替换
+	OverrideActionRevoke  = "revoke"
+)
+
+// RateOverride 管理员为某个货币对或 USD 基准腿钉住的人工汇率，在有效期内优先于数据源
+// FromCurrency 或 ToCurrency 为 USD 时视为 USD 基准腿，影响所有涉及该货币的换算；否则只影响该货币对及其反向
+type RateOverride struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	FromCurrency string     `gorm:"size:10;index:idx_override_pair" json:"fromCurrency"`
+	ToCurrency   string     `gorm:"size:10;index:idx_override_pair" json:"toCurrency"`
+	Rate         float64    `json:"rate"`
+	Reason       string     `gorm:"size:255" json:"reason"`
+	Author       string     `gorm:"size:64" json:"author"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expiresAt"`
+	Active       bool       `gorm:"index" json:"active"` // 撤销或被替换后为 false
+	RevokedBy    string     `gorm:"size:64" json:"revokedBy,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// RateOverrideAudit 人工汇率的操作审计，记录不随人工汇率删除
+type RateOverrideAudit struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	OverrideID uint   `gorm:"index" json:"overrideId"`
+	Action     string `gorm:"size:20" json:"action"`
+	Actor      string `gorm:"size:64" json:"actor"`
+	Detail     string `gorm:"type:text" json:"detail"` // 操作时人工汇率的 JSON 快照
+}
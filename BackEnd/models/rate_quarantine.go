@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// 隔离快照的审核状态
+const (
+	QuarantinePending  = "pending"
+	QuarantineApproved = "approved"
+	QuarantineRejected = "rejected"
+)
+
+// RateQuarantine 未通过校验的汇率快照，由管理员审核后决定是否生效
+type RateQuarantine struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Source    string    `gorm:"size:64" json:"source"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	// 数据源声明的更新时间
+	ProviderUpdatedAt *time.Time `json:"providerUpdatedAt,omitempty"`
+	Rates             string     `gorm:"type:mediumtext" json:"rates"` // USD 基准汇率 JSON
+	Reasons           string     `gorm:"type:text" json:"reasons"`     // 未通过的规则，每行一条
+	Status            string     `gorm:"size:20;index" json:"status"`
+	ReviewedBy        string     `gorm:"size:64" json:"reviewedBy,omitempty"`
+	ReviewedAt        *time.Time `json:"reviewedAt,omitempty"`
+}
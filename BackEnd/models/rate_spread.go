@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 点差的作用范围与单位
+const (
+	SpreadScopeGlobal   = "global"   // 全局默认，Code 为空
+	SpreadScopeCurrency = "currency" // 单个货币，Code 如 JPY
+	SpreadScopePair     = "pair"     // 货币对，Code 如 USD/JPY，同时作用于反向
+	SpreadUnitBps       = "bps"      // 基点，1bps = 0.01%
+	SpreadUnitPct       = "pct"      // 百分比
+)
+
+// RateSpread 买卖点差配置，Value 为买价与卖价之间的总宽度，以中间价为中心对称展开
+type RateSpread struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Scope     string  `gorm:"size:10;uniqueIndex:idx_spread_scope_code,priority:1" json:"scope"`
+	Code      string  `gorm:"size:7;uniqueIndex:idx_spread_scope_code,priority:2" json:"code"`
+	Value     float64 `json:"value"`
+	Unit      string  `gorm:"size:3" json:"unit"`
+	UpdatedBy string  `gorm:"size:64" json:"updatedBy"`
+}
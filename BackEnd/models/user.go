@@ -10,6 +10,8 @@ type User struct {
 	Nickname string `json:"nickname"`                   // 昵称
 	Email    string `gorm:"unique" json:"email"`        // 可为空，不强制验证
 	Avatar   string `json:"avatar"`                     // 头像
+	// 模拟交易中估值和盈亏使用的本位币
+	HomeCurrency string `gorm:"size:3;default:'USD'" json:"homeCurrency"`
 }
 
 /*Role 默认是普通用户 user，管理员为 admin
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// 模拟交易的交易类型与账户
+const (
+	WalletTxDeposit  = "deposit"  // 开户时发放的虚拟初始资金
+	WalletTxExchange = "exchange" // 货币兑换
+
+	LedgerAccountHouse = "house" // 对手方 (平台) 账户，模拟交易的另一方
+)
+
+// Wallet 用户某一币种的模拟钱包，金额均为十进制字符串，避免浮点误差
+type Wallet struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID   uint   `gorm:"uniqueIndex:idx_wallet_user_currency,priority:1;not null" json:"userId"`
+	Currency string `gorm:"size:3;uniqueIndex:idx_wallet_user_currency,priority:2;not null" json:"currency"`
+	Balance  string `gorm:"type:decimal(36,18);not null;default:0" json:"balance"`
+}
+
+// WalletTransaction 一笔模拟交易，(user_id, idempotency_key) 唯一，重复提交返回同一笔交易
+type WalletTransaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	UserID         uint   `gorm:"uniqueIndex:idx_wallet_tx_user_key,priority:1;not null" json:"userId"`
+	IdempotencyKey string `gorm:"size:64;uniqueIndex:idx_wallet_tx_user_key,priority:2;not null" json:"idempotencyKey"`
+	Type           string `gorm:"size:20;not null" json:"type"`
+
+	FromCurrency string `gorm:"size:3" json:"fromCurrency,omitempty"`
+	FromAmount   string `gorm:"type:decimal(36,18)" json:"fromAmount,omitempty"`
+	ToCurrency   string `gorm:"size:3" json:"toCurrency"`
+	ToAmount     string `gorm:"type:decimal(36,18)" json:"toAmount"`
+
+	// 成交时的报价 (from -> to)：Rate 为实际成交价 (买入价)，MidRate 为中间价
+	Rate     string     `gorm:"size:32" json:"rate,omitempty"`
+	MidRate  string     `gorm:"size:32" json:"midRate,omitempty"`
+	Source   string     `gorm:"size:64" json:"source,omitempty"`
+	RateAsOf *time.Time `json:"rateAsOf,omitempty"`
+
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID" json:"entries,omitempty"`
+}
+
+// LedgerEntry 复式记账分录：每笔交易在每个币种上的分录金额之和为 0
+// Amount 为带符号的十进制字符串，正数为入账，负数为出账
+type LedgerEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	TransactionID uint   `gorm:"index;not null" json:"transactionId"`
+	Account       string `gorm:"size:32;index;not null" json:"account"` // "user:<id>" 或 house
+	WalletID      *uint  `gorm:"index" json:"walletId,omitempty"`       // 对手方账户没有钱包
+	Currency      string `gorm:"size:3;not null" json:"currency"`
+	Amount        string `gorm:"type:decimal(36,18);not null" json:"amount"`
+}
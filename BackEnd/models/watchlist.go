@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Watchlist 用户的自选货币对列表，每个用户一个
+type Watchlist struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID  uint             `gorm:"uniqueIndex;not null" json:"userId"`
+	Entries []WatchlistEntry `gorm:"constraint:OnDelete:CASCADE" json:"entries"`
+}
+
+// WatchlistEntry 自选列表中的一个货币对，按 Position 升序展示
+type WatchlistEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	WatchlistID  uint   `gorm:"uniqueIndex:idx_watchlist_pair,priority:1;not null" json:"watchlistId"`
+	FromCurrency string `gorm:"size:10;uniqueIndex:idx_watchlist_pair,priority:2;not null" json:"fromCurrency"`
+	ToCurrency   string `gorm:"size:10;uniqueIndex:idx_watchlist_pair,priority:3;not null" json:"toCurrency"`
+	Position     int    `json:"position"`
+}
@@ -55,11 +55,24 @@ func SetupRouter() *gin.Engine {
 
 		// 如果前端需要单独计算某一对，可以保留这个接口 (可选)
 		api.GET("/exchangeRates/latest", controllers.GetLatestRate)
+		api.GET("/exchangeRates/history", controllers.GetExchangeRateHistory) // 历史交叉汇率序列
+		api.GET("/exchangeRates/candles", controllers.GetExchangeRateCandles) // K 线 (OHLC)
+		api.GET("/exchangeRates/stats", controllers.GetExchangeRateStats)     // 涨跌幅、均线、波动率
+		api.GET("/exchangeRates/at", controllers.GetExchangeRateAt)           // 按日期查询（记账日估值）
+		api.GET("/exchangeRates/matrix", controllers.GetExchangeRateMatrix)   // 交叉汇率矩阵
+		api.GET("/exchangeRates/export", controllers.ExportExchangeRates)     // 导出 CSV / XLSX / JSON
+		api.GET("/exchangeRates/news", controllers.GetRelatedArticles)        // 货币对相关新闻
+		api.GET("/exchangeRates/stream", controllers.StreamExchangeRates)     // SSE 实时推送
+		api.GET("/exchangeRates/ws", controllers.StreamExchangeRatesWS)       // WebSocket 实时推送
+		api.GET("/convert", controllers.ConvertAmount)                        // 金额换算（定点小数）
+		api.POST("/convert/batch", controllers.ConvertBatch)                  // 批量换算
+		api.GET("/currencies", controllers.GetCurrencies)                     // 货币元数据
 
 		// 文章公共接口（无需登录）
-		api.GET("/articles", controllers.GetArticles) // 分页 + 分类
+		api.GET("/articles", controllers.GetArticles) // 分页 + 分类 + 货币标签 (currency=JPY)
 		api.GET("/articles/hot", controllers.GetHotArticles)
-		api.GET("/articles/:id", controllers.GetArticleByID) // 文章详情（自动 views++）
+		api.GET("/articles/search", controllers.SearchArticles) // 全文检索 + 高亮
+		api.GET("/articles/:id", controllers.GetArticleByID)    // 文章详情（自动 views++）
 		api.GET("/articles/:id/comments", controllers.GetCommentsByArticleID)
 		api.GET("/categories", controllers.GetCategories)
 		api.GET("/articles/:id/like", controllers.GetArticleLikes)
@@ -83,6 +96,26 @@ func SetupRouter() *gin.Engine {
 			user.PUT("/profile", controllers.UpdateProfile)
 			user.GET("/favorites", controllers.GetUserFavorites)
 			user.POST("/upload/avatar", controllers.UploadAvatar)
+
+			// 汇率提醒
+			user.GET("/alerts", controllers.GetUserAlerts)
+			user.POST("/alerts", controllers.CreateAlert)
+			user.PUT("/alerts/:id", controllers.UpdateAlert)
+			user.DELETE("/alerts/:id", controllers.DeleteAlert)
+			user.GET("/notifications", controllers.GetUserNotifications)
+			user.PATCH("/notifications/:id/read", controllers.MarkNotificationRead)
+
+			// 自选货币对
+			user.GET("/watchlist", controllers.GetWatchlist)
+			user.POST("/watchlist", controllers.AddWatchlistEntry)
+			user.PUT("/watchlist/order", controllers.ReorderWatchlist)
+			user.DELETE("/watchlist/:id", controllers.DeleteWatchlistEntry)
+			user.GET("/watchlist/quotes", controllers.GetWatchlistQuotes) // 当前汇率 + 24h / 7d 涨跌
+
+			// 模拟交易
+			user.GET("/wallets", controllers.GetWallets) // 钱包估值与盈亏
+			user.POST("/wallets/exchange", controllers.ExchangeWallet)
+			user.GET("/wallets/transactions", controllers.GetWalletTransactions)
 		}
 
 		// ===== 管理员 API =====
@@ -99,10 +132,27 @@ func SetupRouter() *gin.Engine {
 			admin.PUT("/articles/:id", controllers.UpdateArticle)    // 管理员编辑文章
 			admin.DELETE("/articles/:id", controllers.DeleteArticle) // 管理员删除文章
 			admin.POST("/articles/upload/cover", controllers.UploadArticleCover)
+			admin.POST("/articles/currencies/retag", controllers.RetagArticles) // 重新识别全部文章的货币标签
+			admin.GET("/articles/search", controllers.AdminSearchArticles)      // 全文检索，含草稿等未发布文章
 
 			// 分类管理
 			admin.POST("/categories", controllers.CreateCategory)
 			admin.DELETE("/categories/:id", controllers.DeleteCategory)
+
+			// 汇率管理
+			admin.POST("/exchangeRates/backfill", controllers.BackfillExchangeRates) // 从 ECB 等数据源回填历史
+			admin.POST("/exchangeRates/refresh", controllers.RefreshExchangeRates)   // 立即刷新
+			admin.GET("/exchangeRates/status", controllers.GetExchangeRateStatus)    // 调度状态
+			admin.GET("/exchangeRates/quarantine", controllers.GetQuarantinedRates)  // 未通过校验的快照
+			admin.POST("/exchangeRates/quarantine/:id/approve", controllers.ApproveQuarantinedRates)
+			admin.POST("/exchangeRates/quarantine/:id/reject", controllers.RejectQuarantinedRates)
+			admin.GET("/exchangeRates/overrides", controllers.GetRateOverrides) // 人工汇率
+			admin.POST("/exchangeRates/overrides", controllers.CreateRateOverride)
+			admin.DELETE("/exchangeRates/overrides/:id", controllers.RevokeRateOverride)
+			admin.GET("/exchangeRates/overrides/audit", controllers.GetRateOverrideAudits)
+			admin.GET("/exchangeRates/spreads", controllers.GetRateSpreads) // 买卖点差
+			admin.PUT("/exchangeRates/spreads", controllers.SaveRateSpread)
+			admin.DELETE("/exchangeRates/spreads/:id", controllers.DeleteRateSpread)
 		}
 	}
 
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 同时是常见英文单词或缩写的货币代码，单独出现时不打标签，只在 USD/TRY 这样的货币对写法中识别
+var ambiguousCurrencyCodes = map[string]bool{
+	"ALL": true, "TOP": true, "TRY": true, "CUP": true, "BOB": true, "SOS": true, "PEN": true,
+	"GEL": true, "MAD": true, "BAM": true, "KID": true, "IMP": true, "PHP": true, "AMD": true,
+}
+
+// 注册表名称之外的常用叫法
+var currencyAliases = map[string]string{
+	"yen": "JPY", "yuan": "CNY", "renminbi": "CNY", "rmb": "CNY", "sterling": "GBP",
+	"greenback": "USD", "ruble": "RUB", "rouble": "RUB",
+	"美金": "USD", "日圆": "JPY", "港币": "HKD", "澳元": "AUD", "加元": "CAD", "瑞郎": "CHF",
+	"卢布": "RUB", "台币": "TWD", "新元": "SGD", "纽元": "NZD", "韩币": "KRW",
+}
+
+var (
+	currencyCodePattern = regexp.MustCompile(`\b[A-Z]{3}\b`)
+	currencyPairPattern = regexp.MustCompile(`\b([A-Z]{3})/([A-Z]{3})\b`)
+)
+
+// CurrencyMatcher 从文本中识别货币代码与中英文名称，基于构建时的货币注册表
+type CurrencyMatcher struct {
+	english *regexp.Regexp // 英文名称按单词边界匹配，不区分大小写
+	chinese *regexp.Regexp // 中文名称没有单词边界，长名称优先 (白俄罗斯卢布 不会被识别为 卢布)
+	names   map[string]string
+}
+
+// NewCurrencyMatcher 按当前注册表构建匹配器
+func NewCurrencyMatcher() *CurrencyMatcher {
+	m := &CurrencyMatcher{names: make(map[string]string)}
+	for _, c := range ListCurrencies(true) {
+		if c.NameEn != "" {
+			m.names[strings.ToLower(c.NameEn)] = c.Code
+		}
+		if c.NameZh != "" {
+			m.names[c.NameZh] = c.Code
+		}
+	}
+	for alias, code := range currencyAliases {
+		if _, ok := m.names[alias]; !ok && IsKnownCurrency(code) {
+			m.names[alias] = code
+		}
+	}
+
+	var english, chinese []string
+	for name := range m.names {
+		if isASCII(name) {
+			english = append(english, regexp.QuoteMeta(name))
+		} else {
+			chinese = append(chinese, regexp.QuoteMeta(name))
+		}
+	}
+	longestFirst := func(names []string) {
+		sort.Slice(names, func(i, j int) bool {
+			if len(names[i]) != len(names[j]) {
+				return len(names[i]) > len(names[j])
+			}
+			return names[i] < names[j]
+		})
+	}
+	longestFirst(english)
+	longestFirst(chinese)
+	if len(english) > 0 {
+		m.english = regexp.MustCompile(`(?i)\b(?:` + strings.Join(english, "|") + `)s?\b`)
+	}
+	if len(chinese) > 0 {
+		m.chinese = regexp.MustCompile(strings.Join(chinese, "|"))
+	}
+	return m
+}
+
+// Match 返回文本中提到的货币代码，按代码排序去重
+func (m *CurrencyMatcher) Match(texts ...string) []string {
+	found := make(map[string]bool)
+	for _, text := range texts {
+		for _, pair := range currencyPairPattern.FindAllStringSubmatch(text, -1) {
+			for _, code := range pair[1:] {
+				if IsKnownCurrency(code) {
+					found[code] = true
+				}
+			}
+		}
+		for _, code := range currencyCodePattern.FindAllString(text, -1) {
+			if !ambiguousCurrencyCodes[code] && IsKnownCurrency(code) {
+				found[code] = true
+			}
+		}
+		if m.english != nil {
+			for _, name := range m.english.FindAllString(text, -1) {
+				name = strings.ToLower(name)
+				code, ok := m.names[name]
+				if !ok {
+					code, ok = m.names[strings.TrimSuffix(name, "s")]
+				}
+				if ok {
+					found[code] = true
+				}
+			}
+		}
+		if m.chinese != nil {
+			for _, name := range m.chinese.FindAllString(text, -1) {
+				found[m.names[name]] = true
+			}
+		}
+	}
+
+	codes := make([]string, 0, len(found))
+	for code := range found {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// TagArticleCurrencies 识别文章标题、摘要和正文中的货币并替换原有的货币标签，结果写入 article.Currencies
+func TagArticleCurrencies(db *gorm.DB, article *models.Article) error {
+	return tagArticleCurrencies(db, NewCurrencyMatcher(), article)
+}
+
+func tagArticleCurrencies(db *gorm.DB, matcher *CurrencyMatcher, article *models.Article) error {
+	codes := matcher.Match(article.Title, article.Preview, article.Content)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("article_id = ?", article.ID).Delete(&models.ArticleCurrency{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		links := make([]models.ArticleCurrency, len(codes))
+		for i, code := range codes {
+			links[i] = models.ArticleCurrency{ArticleID: article.ID, Currency: code}
+		}
+		return tx.Create(&links).Error
+	})
+	if err != nil {
+		return err
+	}
+	article.Currencies = codes
+	return nil
+}
+
+// RetagAllArticles 重新识别全部文章的货币标签 (用于存量文章或调整识别规则后)，返回处理的文章数
+func RetagAllArticles() (int, error) {
+	matcher := NewCurrencyMatcher()
+	var articles []models.Article
+	count := 0
+	result := global.Db.FindInBatches(&articles, 100, func(tx *gorm.DB, batch int) error {
+		for i := range articles {
+			if err := tagArticleCurrencies(global.Db, matcher, &articles[i]); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, result.Error
+}
+
+// LoadArticleCurrencies 读取文章的货币标签
+func LoadArticleCurrencies(articleID uint) ([]string, error) {
+	var codes []string
+	err := global.Db.Model(&models.ArticleCurrency{}).
+		Where("article_id = ?", articleID).
+		Order("currency ASC").
+		Pluck("currency", &codes).Error
+	return codes, err
+}
+
+// RelatedArticle 货币对接口附带的相关新闻
+type RelatedArticle struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Preview   string    `json:"preview"`
+	Cover     string    `json:"cover,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RelatedArticles 提到 from 或 to 的已发布文章，同时提到两种货币的排在前面，其次按发布时间倒序
+func RelatedArticles(from, to string, limit int) ([]RelatedArticle, error) {
+	related := []RelatedArticle{}
+	err := global.Db.Model(&models.Article{}).
+		Select("articles.id, articles.title, articles.preview, articles.cover, articles.created_at").
+		Joins("JOIN article_currencies ON article_currencies.article_id = articles.id").
+		Where("article_currencies.currency IN ? AND articles.status = ?", []string{from, to}, "published").
+		Group("articles.id").
+		Order("COUNT(*) DESC, articles.created_at DESC").
+		Limit(limit).
+		Scan(&related).Error
+	return related, err
+}
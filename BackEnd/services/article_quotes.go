@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 文章正文中的汇率占位符：{{rate USD/CNY}}、{{convert 100 EUR JPY}}
+var articleQuotePattern = regexp.MustCompile(`\{\{\s*(rate|convert)\s+([^{}]*?)\s*\}\}`)
+
+// 正文中展示汇率保留的小数位数
+const articleRateDisplayPlaces = 4
+
+// ArticleQuote 文章中嵌入的一个汇率引用，同一占位符出现多次只列出一次
+type ArticleQuote struct {
+	Token     string      `json:"token"` // 原始占位符
+	Type      string      `json:"type"`  // rate | convert
+	From      string      `json:"from,omitempty"`
+	To        string      `json:"to,omitempty"`
+	Amount    string      `json:"amount,omitempty"` // convert 的金额
+	Current   *QuoteValue `json:"current"`          // 当前快照，不可用时为 null
+	AtPublish *QuoteValue `json:"atPublish"`        // 文章发布当天的快照，没有历史数据时为 null
+	Text      string      `json:"text"`             // 替换到 renderedContent 中的文本
+	Error     string      `json:"error,omitempty"`  // 占位符无法解析时的原因，此时正文保留原样
+}
+
+// QuoteValue 某份快照下的中间价与换算结果
+type QuoteValue struct {
+	Rate            string    `json:"rate"`
+	ConvertedAmount string    `json:"convertedAmount,omitempty"`
+	AsOf            time.Time `json:"asOf"`
+	Overridden      bool      `json:"overridden,omitempty"`
+}
+
+// RenderArticleContent 展开正文中的汇率占位符，同时给出当前与发布当天 (publishedAt) 的报价，
+// 如 "7.2100 (7.1800 at time of writing)"；正文没有占位符时不读取任何快照
+func RenderArticleContent(ctx context.Context, content string, publishedAt time.Time) (string, []ArticleQuote) {
+	matches := articleQuotePattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return content, []ArticleQuote{}
+	}
+
+	current, err := LoadBaseSnapshot(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrRatesUnavailable) {
+			log.Printf("Loading current rates for article quotes failed: %v\n", err)
+		}
+		current = &BaseSnapshot{}
+	}
+	overrides, err := LoadActiveOverrides(time.Now())
+	if err != nil {
+		log.Printf("Loading rate overrides for article quotes failed: %v\n", err)
+	}
+	// 发布当天缺数据时取之前最近一天；文章早于全部历史时没有发布时报价
+	var published *BaseSnapshot
+	if dated, err := LoadSnapshotOn(publishedAt, MissingDayPrevious); err == nil {
+		published = dated.BaseSnapshot
+	} else if !errors.Is(err, ErrRatesUnavailable) {
+		log.Printf("Loading publish-date rates for article quotes failed: %v\n", err)
+	}
+
+	quotes := make([]ArticleQuote, 0, len(matches))
+	byToken := make(map[string]string, len(matches))
+	for _, m := range matches {
+		if _, ok := byToken[m[0]]; ok {
+			continue
+		}
+		q := buildArticleQuote(m[0], m[1], strings.Fields(m[2]), current, overrides, published)
+		byToken[m[0]] = q.Text
+		quotes = append(quotes, q)
+	}
+
+	rendered := articleQuotePattern.ReplaceAllStringFunc(content, func(token string) string {
+		return byToken[token]
+	})
+	return rendered, quotes
+}
+
+func buildArticleQuote(token, kind string, args []string, current *BaseSnapshot, overrides *OverrideSet, published *BaseSnapshot) ArticleQuote {
+	q := ArticleQuote{Token: token, Type: kind, Text: token}
+
+	var err error
+	switch kind {
+	case "rate":
+		if len(args) != 1 {
+			err = errors.New("usage: {{rate USD/CNY}}")
+			break
+		}
+		q.From, q.To, err = ParsePair(args[0])
+	case "convert":
+		if len(args) != 3 {
+			err = errors.New("usage: {{convert 100 EUR JPY}}")
+			break
+		}
+		q.Amount, q.From, q.To = args[0], strings.ToUpper(args[1]), strings.ToUpper(args[2])
+		_, err = ParseDecimal(q.Amount)
+	}
+	if err == nil {
+		for _, code := range []string{q.From, q.To} {
+			if !IsKnownCurrency(code) {
+				err = fmt.Errorf("unknown currency: %s", code)
+				break
+			}
+		}
+	}
+	if err != nil {
+		q.Error = err.Error()
+		return q
+	}
+
+	if usdToFrom, usdToTo, overridden, ok := overrides.Legs(current, q.From, q.To); ok {
+		q.Current = articleQuoteValue(q, usdToFrom, usdToTo, current.AsOf())
+		if q.Current != nil {
+			q.Current.Overridden = overridden
+		}
+	}
+	if published != nil {
+		if usdToFrom, ok := published.USDRate(q.From); ok {
+			if usdToTo, ok := published.USDRate(q.To); ok {
+				q.AtPublish = articleQuoteValue(q, usdToFrom, usdToTo, published.AsOf())
+			}
+		}
+	}
+
+	display := func(v *QuoteValue) string {
+		if q.Type == "convert" {
+			return v.ConvertedAmount + " " + q.To
+		}
+		rate, _ := ParseDecimal(v.Rate)
+		return RoundRat(rate, articleRateDisplayPlaces, RoundHalfEven).FloatString(articleRateDisplayPlaces)
+	}
+	switch {
+	case q.Current != nil && q.AtPublish != nil && display(q.Current) != display(q.AtPublish):
+		q.Text = fmt.Sprintf("%s (%s at time of writing)", display(q.Current), display(q.AtPublish))
+	case q.Current != nil:
+		q.Text = display(q.Current)
+	case q.AtPublish != nil:
+		q.Text = display(q.AtPublish) + " (at time of writing)"
+	}
+	return q
+}
+
+func articleQuoteValue(q ArticleQuote, usdToFrom, usdToTo float64, asOf time.Time) *QuoteValue {
+	amount := q.Amount
+	if amount == "" {
+		amount = "1"
+	}
+	conv, err := ConvertAmount(q.From, q.To, amount, usdToFrom, usdToTo, Spread{}, SideMid, RoundHalfEven)
+	if err != nil {
+		return nil
+	}
+	v := &QuoteValue{Rate: conv.Mid, AsOf: asOf}
+	if q.Type == "convert" {
+		v.ConvertedAmount = conv.ConvertedAmount
+	}
+	return v
+}
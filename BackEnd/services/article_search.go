@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/models"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	SearchEngineMySQL  = "mysql"
+	SearchEngineMemory = "memory"
+
+	SearchMaxQueryLen = 100 // 查询词最多字符数
+	SearchMaxLimit    = 50
+	searchSnippetLen  = 120 // 摘要片段的字符数
+)
+
+var ErrEmptySearchQuery = errors.New("search query is empty")
+
+// SearchIndex 文章全文检索，文章创建、更新、删除时由调用方同步索引
+type SearchIndex interface {
+	Index(article *models.Article) error
+	Remove(id uint) error
+	Search(ctx context.Context, q SearchQuery) (*SearchResult, error)
+}
+
+// SearchQuery 检索条件，除 Query 外均为可选过滤
+type SearchQuery struct {
+	Query      string
+	CategoryID uint
+	Status     string
+	From       time.Time // 发布时间下界 (含)
+	To         time.Time // 发布时间上界 (不含)
+	Page       int
+	Limit      int
+}
+
+// SearchHit 一条检索结果，TitleHighlight 与 Snippet 已做 HTML 转义，命中的词用 <em> 包裹
+type SearchHit struct {
+	ID             uint      `json:"id"`
+	Title          string    `json:"title"`
+	Preview        string    `json:"preview"`
+	Cover          string    `json:"cover,omitempty"`
+	CategoryID     uint      `json:"categoryId,omitempty"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Score          float64   `json:"score"`
+	TitleHighlight string    `json:"titleHighlight"`
+	Snippet        string    `json:"snippet"`
+}
+
+// SearchResult 一页检索结果
+type SearchResult struct {
+	Total int64       `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+var (
+	searchIndexMu sync.RWMutex
+	searchIndex   SearchIndex
+)
+
+// InitSearchIndex 按配置 search.engine 初始化文章检索；MySQL 无法建立 ngram 全文索引时退回进程内索引
+func InitSearchIndex() error {
+	if config.AppConfig.Search.Engine != SearchEngineMemory {
+		index, err := NewMySQLSearchIndex()
+		if err == nil {
+			SetSearchIndex(index)
+			return nil
+		}
+		log.Printf("MySQL full-text search unavailable, falling back to in-process index: %v\n", err)
+	}
+
+	index := NewMemorySearchIndex()
+	if err := index.Load(); err != nil {
+		SetSearchIndex(index)
+		return err
+	}
+	SetSearchIndex(index)
+	return nil
+}
+
+// SetSearchIndex 替换当前使用的检索实现
+func SetSearchIndex(index SearchIndex) {
+	searchIndexMu.Lock()
+	searchIndex = index
+	searchIndexMu.Unlock()
+}
+
+// ArticleSearch 当前使用的检索实现，未初始化时为空的进程内索引
+func ArticleSearch() SearchIndex {
+	searchIndexMu.RLock()
+	index := searchIndex
+	searchIndexMu.RUnlock()
+	if index != nil {
+		return index
+	}
+
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	if searchIndex == nil {
+		searchIndex = NewMemorySearchIndex()
+	}
+	return searchIndex
+}
+
+// normalize 校验查询词并填充分页默认值
+func (q *SearchQuery) normalize() error {
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return ErrEmptySearchQuery
+	}
+	if runes := []rune(q.Query); len(runes) > SearchMaxQueryLen {
+		q.Query = string(runes[:SearchMaxQueryLen])
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 {
+		q.Limit = 10
+	}
+	if q.Limit > SearchMaxLimit {
+		q.Limit = SearchMaxLimit
+	}
+	return nil
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchTokens 分词：拉丁字母与数字按单词切分并转小写，中日韩文字按二元组 (bigram) 切分，
+// 与 MySQL ngram 解析器默认的 ngram_token_size=2 一致；单个汉字保留为一个词
+func searchTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// highlightTerms 用于高亮的词：查询中的每个词本身，加上中文词的二元组 (文章中可能只出现其中一部分)
+func highlightTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(t string) {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		field = strings.Trim(field, `+-~<>()*"'`)
+		add(field)
+		if len([]rune(field)) > 2 {
+			for _, t := range searchTokens(field) {
+				add(t)
+			}
+		}
+	}
+	return terms
+}
+
+// markTerms 标记文本中与任一词匹配的字符 (不区分大小写)
+func markTerms(runes []rune, terms []string) []bool {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			match := true
+			for j := range t {
+				if lower[i+j] != t[j] {
+					match = false
+					break
+				}
+			}
+			if match {
+				for j := range t {
+					marked[i+j] = true
+				}
+			}
+		}
+	}
+	return marked
+}
+
+// renderHighlight 转义 HTML，并用 <em> 包裹连续的命中字符
+func renderHighlight(runes []rune, marked []bool) string {
+	var b strings.Builder
+	open := false
+	for i, r := range runes {
+		if marked[i] != open {
+			if open {
+				b.WriteString("</em>")
+			} else {
+				b.WriteString("<em>")
+			}
+			open = marked[i]
+		}
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	if open {
+		b.WriteString("</em>")
+	}
+	return b.String()
+}
+
+// Highlight 高亮整段文本
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+	return renderHighlight(runes, markTerms(runes, terms))
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Snippet 从第一个命中的字段中截取命中位置附近的片段并高亮，都未命中时返回第一个非空字段的开头
+func Snippet(terms []string, fields ...string) string {
+	var fallback []rune
+	for _, field := range fields {
+		text := strings.Join(strings.Fields(htmlTagPattern.ReplaceAllString(field, " ")), " ")
+		runes := []rune(text)
+		if fallback == nil && len(runes) > 0 {
+			fallback = runes
+		}
+		marked := markTerms(runes, terms)
+
+		first := -1
+		for i, m := range marked {
+			if m {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		start := first - searchSnippetLen/4
+		if start < 0 {
+			start = 0
+		}
+		end := start + searchSnippetLen
+		if end > len(runes) {
+			end = len(runes)
+		}
+		snippet := renderHighlight(runes[start:end], marked[start:end])
+		if start > 0 {
+			snippet = "…" + snippet
+		}
+		if end < len(runes) {
+			snippet += "…"
+		}
+		return snippet
+	}
+
+	if len(fallback) > searchSnippetLen {
+		return html.EscapeString(string(fallback[:searchSnippetLen])) + "…"
+	}
+	return html.EscapeString(string(fallback))
+}
+
+// newSearchHit 由文章生成检索结果并计算高亮
+func newSearchHit(article *models.Article, score float64, terms []string) SearchHit {
+	return SearchHit{
+		ID:             article.ID,
+		Title:          article.Title,
+		Preview:        article.Preview,
+		Cover:          article.Cover,
+		CategoryID:     article.CategoryID,
+		Status:         article.Status,
+		CreatedAt:      article.CreatedAt,
+		Score:          score,
+		TitleHighlight: Highlight(article.Title, terms),
+		Snippet:        Snippet(terms, article.Content, article.Preview),
+	}
+}
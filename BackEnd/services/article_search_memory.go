@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"math"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 各字段命中的权重
+const (
+	memoryTitleWeight   = 3
+	memoryPreviewWeight = 2
+	memoryContentWeight = 1
+)
+
+// MemorySearchIndex 进程内倒排索引，用于单机部署、测试或数据库不支持 ngram 全文索引时
+// 多实例部署时各实例只能看到自己写入的变更，生产环境应使用 MySQL 实现
+type MemorySearchIndex struct {
+	mu       sync.RWMutex
+	docs     map[uint]*memoryDoc
+	postings map[string]map[uint]float64 // token -> 文章 ID -> 加权词频
+}
+
+type memoryDoc struct {
+	article models.Article
+	tokens  []string // 文章包含的词，删除时用于清理倒排表
+}
+
+func NewMemorySearchIndex() *MemorySearchIndex {
+	return &MemorySearchIndex{
+		docs:     make(map[uint]*memoryDoc),
+		postings: make(map[string]map[uint]float64),
+	}
+}
+
+// Load 从数据库载入全部文章
+func (m *MemorySearchIndex) Load() error {
+	var articles []models.Article
+	return global.Db.FindInBatches(&articles, 200, func(tx *gorm.DB, batch int) error {
+		for i := range articles {
+			m.Index(&articles[i])
+		}
+		return nil
+	}).Error
+}
+
+func (m *MemorySearchIndex) Index(article *models.Article) error {
+	weights := make(map[string]float64)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{article.Title, memoryTitleWeight},
+		{article.Preview, memoryPreviewWeight},
+		{htmlTagPattern.ReplaceAllString(article.Content, " "), memoryContentWeight},
+	} {
+		for _, token := range searchTokens(field.text) {
+			weights[token] += field.weight
+		}
+	}
+
+	doc := &memoryDoc{article: *article, tokens: make([]string, 0, len(weights))}
+	doc.article.Currencies = nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(article.ID)
+	for token, weight := range weights {
+		if m.postings[token] == nil {
+			m.postings[token] = make(map[uint]float64)
+		}
+		m.postings[token][article.ID] = weight
+		doc.tokens = append(doc.tokens, token)
+	}
+	m.docs[article.ID] = doc
+	return nil
+}
+
+func (m *MemorySearchIndex) Remove(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(id)
+	return nil
+}
+
+func (m *MemorySearchIndex) remove(id uint) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	for _, token := range doc.tokens {
+		delete(m.postings[token], id)
+		if len(m.postings[token]) == 0 {
+			delete(m.postings, token)
+		}
+	}
+	delete(m.docs, id)
+}
+
+// Search 按 BM25 风格的词频饱和 × IDF 打分，命中任一查询词即返回
+func (m *MemorySearchIndex) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make(map[uint]float64)
+	seen := make(map[string]bool)
+	total := float64(len(m.docs))
+	for _, token := range searchTokens(q.Query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		posting := m.postings[token]
+		df := float64(len(posting))
+		idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			scores[id] += idf * tf / (tf + 1.2)
+		}
+	}
+
+	matched := make([]*memoryDoc, 0, len(scores))
+	for id := range scores {
+		doc := m.docs[id]
+		a := &doc.article
+		if q.CategoryID != 0 && a.CategoryID != q.CategoryID {
+			continue
+		}
+		if q.Status != "" && a.Status != q.Status {
+			continue
+		}
+		if !q.From.IsZero() && a.CreatedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !a.CreatedAt.Before(q.To) {
+			continue
+		}
+		matched = append(matched, doc)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		si, sj := scores[matched[i].article.ID], scores[matched[j].article.ID]
+		if si != sj {
+			return si > sj
+		}
+		return matched[i].article.CreatedAt.After(matched[j].article.CreatedAt)
+	})
+
+	result := &SearchResult{Total: int64(len(matched)), Hits: []SearchHit{}}
+	start := (q.Page - 1) * q.Limit
+	if start >= len(matched) {
+		return result, nil
+	}
+	end := start + q.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	terms := highlightTerms(q.Query)
+	for _, doc := range matched[start:end] {
+		result.Hits = append(result.Hits, newSearchHit(&doc.article, scores[doc.article.ID], terms))
+	}
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"exchangeapp/models"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Fed 加息", []string{"fed", "加息"}},
+		{"美元汇率", []string{"美元", "元汇", "汇率"}},
+		{"USD兑CNY", []string{"usd", "兑", "cny"}},
+		{"降息！", []string{"降息"}},
+		{"GDP 2.5%", []string{"gdp", "2", "5"}},
+		{"円安・ドル高", []string{"円安", "ドル", "ル高"}},
+	}
+	for _, tt := range tests {
+		if got := searchTokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"USD/CNY", []string{"usd", "cny"}, "<em>USD</em>/<em>CNY</em>"},
+		{"美元汇率走强", highlightTerms("美元汇率"), "<em>美元汇率</em>走强"},
+		{"人民币汇率", highlightTerms("美元汇率"), "人民币<em>汇率</em>"},
+		{"<b>Fed</b> & 加息", []string{"fed", "加息"}, "&lt;b&gt;<em>Fed</em>&lt;/b&gt; &amp; <em>加息</em>"},
+		{`<script>alert("fed")</script>`, []string{"fed"}, "&lt;script&gt;alert(&#34;<em>fed</em>&#34;)&lt;/script&gt;"},
+		{"no match", []string{"fed"}, "no match"},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.text, tt.terms); got != tt.want {
+			t.Errorf("Highlight(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("x ", 100) + "target" + strings.Repeat(" y", 100)
+
+	tests := []struct {
+		name   string
+		terms  []string
+		fields []string
+		check  func(string) bool
+		want   string
+	}{
+		{
+			name:   "tags stripped and text escaped",
+			terms:  []string{"fed"},
+			fields: []string{"<p>The <b>Fed</b> raised rates & more</p>", "preview"},
+			want:   "The <em>Fed</em> raised rates &amp; more",
+		},
+		{
+			name:   "falls through to later field",
+			terms:  []string{"预期"},
+			fields: []string{"<p>正文</p>", "降息预期升温"},
+			want:   "降息<em>预期</em>升温",
+		},
+		{
+			name:   "no match returns escaped first field",
+			terms:  []string{"fed"},
+			fields: []string{"", "a<b"},
+			want:   "a&lt;b",
+		},
+		{
+			name:   "long text trimmed around first hit",
+			terms:  []string{"target"},
+			fields: []string{long},
+			check: func(s string) bool {
+				return strings.HasPrefix(s, "…") && strings.HasSuffix(s, "…") &&
+					strings.Contains(s, "<em>target</em>") && len([]rune(s)) < len(long)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Snippet(tt.terms, tt.fields...)
+			if tt.check != nil {
+				if !tt.check(got) {
+					t.Errorf("Snippet = %q", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Snippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySearchIndexFilters(t *testing.T) {
+	day := func(d string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02", d, time.Local)
+		return t
+	}
+	index := NewMemorySearchIndex()
+	for _, a := range []models.Article{
+		{ID: 1, Title: "美元汇率走强", CategoryID: 1, Status: "published", CreatedAt: day("2024-01-10")},
+		{ID: 2, Title: "美元指数回落", CategoryID: 2, Status: "published", CreatedAt: day("2024-01-20")},
+		{ID: 3, Title: "降息预期", Content: "<p>美元承压</p>", CategoryID: 1, Status: "draft", CreatedAt: day("2024-02-01")},
+		{ID: 4, Title: "黄金价格", CategoryID: 1, Status: "published", CreatedAt: day("2024-01-15")},
+	} {
+		index.Index(&a)
+	}
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []uint
+	}{
+		{"no filter", SearchQuery{}, []uint{1, 2, 3}},
+		{"category", SearchQuery{CategoryID: 1}, []uint{1, 3}},
+		{"status", SearchQuery{Status: "published"}, []uint{1, 2}},
+		{"from inclusive", SearchQuery{From: day("2024-01-20")}, []uint{2, 3}},
+		{"to exclusive", SearchQuery{To: day("2024-01-20")}, []uint{1}},
+		{"date range", SearchQuery{From: day("2024-01-15"), To: day("2024-02-01")}, []uint{2}},
+		{"combined", SearchQuery{CategoryID: 1, Status: "published"}, []uint{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Query = "美元"
+			result, err := index.Search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []uint
+			for _, hit := range result.Hits {
+				ids = append(ids, hit.ID)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			if !reflect.DeepEqual(ids, tt.want) || result.Total != int64(len(tt.want)) {
+				t.Errorf("ids = %v (total %d), want %v", ids, result.Total, tt.want)
+			}
+		})
+	}
+
+	// 标题命中的权重高于正文
+	result, _ := index.Search(context.Background(), SearchQuery{Query: "美元", CategoryID: 1})
+	if len(result.Hits) != 2 || result.Hits[0].ID != 1 {
+		t.Errorf("title match should rank first, got %+v", result.Hits)
+	}
+
+	index.Remove(2)
+	if result, _ := index.Search(context.Background(), SearchQuery{Query: "指数"}); result.Total != 0 {
+		t.Errorf("removed article still found: %+v", result.Hits)
+	}
+
+	if _, err := index.Search(context.Background(), SearchQuery{Query: "  "}); err != ErrEmptySearchQuery {
+		t.Errorf("blank query err = %v", err)
+	}
+}
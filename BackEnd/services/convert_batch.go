@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConvertBatchMaxItems 单次批量换算最多条目数
+const ConvertBatchMaxItems = 1000
+
+// ConvertItem 批量换算的单条请求，Date 为空时使用当前快照
+type ConvertItem struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+	Date   string `json:"date,omitempty"` // YYYY-MM-DD
+	Side   string `json:"side,omitempty"` // buy / sell，为空时使用中间价
+}
+
+// ConvertItemResult 单条换算结果，Result 与 Error 二选一
+type ConvertItemResult struct {
+	Index  int         `json:"index"`
+	Result *Conversion `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ConvertBatch 批量换算：当前快照只读取一次，带日期的条目按日期各读取一次历史快照，
+// 保证同一请求内的结果不会混用刷新前后的两份数据。单条失败不影响其他条目
+// 人工汇率只作用于不带日期的条目
+func ConvertBatch(ctx context.Context, items []ConvertItem, mode RoundingMode) ([]ConvertItemResult, error) {
+	var latest *BaseSnapshot
+	var overrides *OverrideSet
+	for _, item := range items {
+		if item.Date == "" {
+			snapshot, err := LoadBaseSnapshot(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if overrides, err = LoadActiveOverrides(time.Now()); err != nil {
+				return nil, err
+			}
+			latest = snapshot
+			break
+		}
+	}
+
+	spreads, err := LoadSpreads()
+	if err != nil {
+		return nil, err
+	}
+
+	historical := make(map[string]*BaseSnapshot)
+	historicalErr := make(map[string]error)
+	snapshotFor := func(date string) (*BaseSnapshot, error) {
+		if date == "" {
+			return latest, nil
+		}
+		if snapshot, ok := historical[date]; ok {
+			return snapshot, historicalErr[date]
+		}
+		snapshot, err := loadDatedSnapshot(date)
+		historical[date], historicalErr[date] = snapshot, err
+		return snapshot, err
+	}
+
+	results := make([]ConvertItemResult, len(items))
+	for i, item := range items {
+		results[i].Index = i
+		var itemOverrides *OverrideSet
+		if item.Date == "" {
+			itemOverrides = overrides
+		}
+		conversion, err := convertItem(item, mode, snapshotFor, itemOverrides, spreads)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Result = conversion
+	}
+	return results, nil
+}
+
+func convertItem(item ConvertItem, mode RoundingMode, snapshotFor func(date string) (*BaseSnapshot, error), overrides *OverrideSet, spreads *SpreadSet) (*Conversion, error) {
+	from, to := strings.ToUpper(item.From), strings.ToUpper(item.To)
+	if from == "" || to == "" || item.Amount == "" {
+		return nil, errors.New("from, to and amount are required")
+	}
+	if !IsKnownCurrency(from) {
+		return nil, fmt.Errorf("unknown currency: %s", from)
+	}
+	if !IsKnownCurrency(to) {
+		return nil, fmt.Errorf("unknown currency: %s", to)
+	}
+	if _, err := ParseDecimal(item.Amount); err != nil {
+		return nil, err
+	}
+	side, err := ParseQuoteSide(item.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := snapshotFor(item.Date)
+	if err != nil {
+		return nil, err
+	}
+	usdToFrom, usdToTo, overridden, ok := overrides.Legs(snapshot, from, to)
+	if !ok {
+		return nil, fmt.Errorf("no rate for %s/%s", from, to)
+	}
+
+	conversion, err := ConvertAmount(from, to, item.Amount, usdToFrom, usdToTo, spreads.For(from, to), side, mode)
+	if err != nil {
+		return nil, err
+	}
+	conversion.Timestamp = snapshot.FetchedAt
+	conversion.AsOf = snapshot.AsOf()
+	conversion.Source = snapshot.Source
+	conversion.Overridden = overridden
+	if item.Date == "" {
+		conversion.Stale = snapshot.Stale(time.Now())
+	}
+	return conversion, nil
+}
+
+// loadDatedSnapshot 读取 date 当天生效的历史快照，当天缺失时按配置的 missingDay 处理
+func loadDatedSnapshot(date string) (*BaseSnapshot, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	mode, err := ParseMissingDayMode("")
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := LoadSnapshotOn(day, mode)
+	if errors.Is(err, ErrRatesUnavailable) {
+		return nil, fmt.Errorf("no rates on or before %s", date)
+	}
+	if errors.Is(err, ErrNoSnapshotOnDate) {
+		return nil, fmt.Errorf("no rates on %s", date)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.BaseSnapshot, nil
+}
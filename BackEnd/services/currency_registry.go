@@ -0,0 +1,93 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"log"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm/clause"
+)
+
+//go:embed data/currencies.json
+var currencyDataset []byte
+
+var (
+	currencyMu       sync.RWMutex
+	currencyRegistry map[string]models.Currency
+)
+
+func init() {
+	// 先用内置数据集兜底，保证数据库不可用时注册表也可用
+	var currencies []models.Currency
+	if err := json.Unmarshal(currencyDataset, &currencies); err != nil {
+		log.Fatalf("Invalid embedded currency dataset: %v", err)
+	}
+	setCurrencyRegistry(currencies)
+}
+
+// InitCurrencyRegistry 将内置数据集写入 currencies 表，再从数据库加载注册表
+// 内置数据集是货币信息的唯一来源：已存在的记录按数据集更新 (名称修正、最小单位调整、标记停用)，
+// 数据集之外的记录保持不变
+func InitCurrencyRegistry() error {
+	var seed []models.Currency
+	if err := json.Unmarshal(currencyDataset, &seed); err != nil {
+		return err
+	}
+	if err := global.Db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"numeric", "name_en", "name_zh", "symbol", "minor_units", "deprecated"}),
+	}).CreateInBatches(seed, 100).Error; err != nil {
+		return err
+	}
+
+	var currencies []models.Currency
+	if err := global.Db.Find(&currencies).Error; err != nil {
+		return err
+	}
+	setCurrencyRegistry(currencies)
+	return nil
+}
+
+func setCurrencyRegistry(currencies []models.Currency) {
+	registry := make(map[string]models.Currency, len(currencies))
+	for _, c := range currencies {
+		registry[c.Code] = c
+	}
+	currencyMu.Lock()
+	currencyRegistry = registry
+	currencyMu.Unlock()
+}
+
+// LookupCurrency 查询货币信息
+func LookupCurrency(code string) (models.Currency, bool) {
+	currencyMu.RLock()
+	defer currencyMu.RUnlock()
+	c, ok := currencyRegistry[code]
+	return c, ok
+}
+
+// IsKnownCurrency 货币代码是否在注册表中
+func IsKnownCurrency(code string) bool {
+	_, ok := LookupCurrency(code)
+	return ok
+}
+
+// ListCurrencies 按代码排序返回注册表，includeDeprecated 为 false 时只返回流通中的货币
+func ListCurrencies(includeDeprecated bool) []models.Currency {
+	currencyMu.RLock()
+	list := make([]models.Currency, 0, len(currencyRegistry))
+	for _, c := range currencyRegistry {
+		if c.Deprecated && !includeDeprecated {
+			continue
+		}
+		list = append(list, c)
+	}
+	currencyMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
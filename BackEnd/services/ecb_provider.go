@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ECBDailyURL   = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ECBHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// ECBProvider 欧洲央行参考汇率，原始数据以 EUR 为基准，
+// 返回前由 FetchFromProviders / BackfillHistory 统一换算为 USD 基准
+// 只发布约 30 种货币，作为实时数据源时快照标记为 Partial，其余货币沿用上一份快照
+type ECBProvider struct {
+	name          string
+	url           string
+	historyURL    string
+	client        *http.Client
+	historyClient *http.Client
+}
+
+// ecbEnvelope 对应 eurofxref-daily.xml / eurofxref-hist.xml 的结构:
+//
+//	<gesmes:Envelope>
+//	  <Cube>
+//	    <Cube time="2024-01-02">
+//	      <Cube currency="USD" rate="1.0956"/>
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func (p *ECBProvider) Name() string { return p.name }
+
+// FetchLatest 拉取 eurofxref-daily.xml
+func (p *ECBProvider) FetchLatest(ctx context.Context) (*RateSnapshot, error) {
+	snapshots, err := p.fetch(ctx, p.client, p.url)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, errors.New("ECB feed contains no rates")
+	}
+	// 文件按日期倒序排列，第一项即最新
+	return snapshots[0], nil
+}
+
+// FetchHistory 拉取 eurofxref-hist.xml，返回自 1999 年以来的全部快照（日期倒序）
+func (p *ECBProvider) FetchHistory(ctx context.Context) ([]*RateSnapshot, error) {
+	return p.fetch(ctx, p.historyClient, p.historyURL)
+}
+
+func (p *ECBProvider) fetch(ctx context.Context, client *http.Client, url string) ([]*RateSnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status: %d", resp.StatusCode)
+	}
+
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("xml decode failed: %w", err)
+	}
+
+	snapshots := make([]*RateSnapshot, 0, len(envelope.Cube.Days))
+	for _, day := range envelope.Cube.Days {
+		date, err := time.ParseInLocation("2006-01-02", day.Time, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECB date %q: %w", day.Time, err)
+		}
+		rates := make(map[string]float64, len(day.Rates))
+		for _, r := range day.Rates {
+			rates[r.Currency] = r.Rate
+		}
+		snapshots = append(snapshots, &RateSnapshot{Base: "EUR", Rates: rates, UpdatedAt: date, Partial: true})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/config"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ecbServer 用 testdata 下的 eurofxref 文件模拟 ECB 接口
+func ecbServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/daily.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/eurofxref-daily.xml")
+	})
+	mux.HandleFunc("/hist.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/eurofxref-hist.xml")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestECBProvider(t *testing.T, srv *httptest.Server) *ECBProvider {
+	t.Helper()
+	p, err := NewRateProvider(config.RateProviderConfig{
+		Name:       "ecb",
+		Type:       "ecb",
+		URL:        srv.URL + "/daily.xml",
+		HistoryURL: srv.URL + "/hist.xml",
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*ECBProvider)
+}
+
+func assertRate(t *testing.T, rates map[string]float64, code string, want float64) {
+	t.Helper()
+	got, ok := rates[code]
+	if !ok {
+		t.Errorf("%s missing", code)
+		return
+	}
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %.10f, want %.10f", code, got, want)
+	}
+}
+
+func TestECBLatestRebasedToUSD(t *testing.T) {
+	provider := newTestECBProvider(t, ecbServer(t))
+
+	snapshot, err := FetchFromProviders(context.Background(), []RateProvider{provider}, nil)
+	if err != nil {
+		t.Fatalf("FetchFromProviders: %v", err)
+	}
+	if snapshot.Base != "USD" || snapshot.Provider != "ecb" || !snapshot.Partial {
+		t.Errorf("snapshot = base %s provider %s partial %v", snapshot.Base, snapshot.Provider, snapshot.Partial)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local); !snapshot.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", snapshot.UpdatedAt, want)
+	}
+
+	// Rate(USD -> X) = Rate(EUR -> X) / Rate(EUR -> USD)
+	assertRate(t, snapshot.Rates, "USD", 1)
+	assertRate(t, snapshot.Rates, "EUR", 1/1.0956)
+	assertRate(t, snapshot.Rates, "CNY", 7.8067/1.0956)
+	assertRate(t, snapshot.Rates, "JPY", 155.13/1.0956)
+	assertRate(t, snapshot.Rates, "GBP", 0.86655/1.0956)
+}
+
+func TestECBHistoryRebase(t *testing.T) {
+	provider := newTestECBProvider(t, ecbServer(t))
+
+	snapshots, err := provider.FetchHistory(context.Background())
+	if err != nil {
+		t.Fatalf("FetchHistory: %v", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snapshots))
+	}
+
+	tests := []struct {
+		date    string
+		cny     float64
+		wantErr bool
+	}{
+		{"2024-01-03", 7.8034 / 1.0919, false},
+		{"2024-01-02", 7.8067 / 1.0956, false},
+		{"1999-01-04", 0, true}, // 缺少 USD 的一天无法换算
+	}
+	for i, tt := range tests {
+		s := snapshots[i]
+		if got := s.UpdatedAt.Format("2006-01-02"); got != tt.date {
+			t.Errorf("snapshot %d date = %s, want %s", i, got, tt.date)
+			continue
+		}
+		err := s.ToUSDBase()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected rebase error without USD cube", tt.date)
+			}
+			if s.Base != "EUR" {
+				t.Errorf("%s: failed rebase changed base to %s", tt.date, s.Base)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.date, err)
+			continue
+		}
+		assertRate(t, s.Rates, "CNY", tt.cny)
+	}
+}
+
+func TestECBMissingUSDFailsOver(t *testing.T) {
+	noUSD := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<Envelope><Cube><Cube time="2024-01-02"><Cube currency="JPY" rate="155.13"/></Cube></Cube></Envelope>`))
+	}))
+	t.Cleanup(noUSD.Close)
+	backup := jsonServer(t, http.StatusOK, `{"rates":{"JPY":141.6}}`, nil)
+
+	providers := []RateProvider{
+		newTestProvider(t, config.RateProviderConfig{Name: "ecb", Type: "ecb", URL: noUSD.URL}),
+		newTestProvider(t, config.RateProviderConfig{Name: "backup", Type: "http-json", URL: backup.URL, Base: "USD", RatesPath: "rates"}),
+	}
+	snapshot, err := FetchFromProviders(context.Background(), providers, nil)
+	if err != nil {
+		t.Fatalf("FetchFromProviders: %v", err)
+	}
+	if snapshot.Provider != "backup" {
+		t.Errorf("provider = %s, want backup", snapshot.Provider)
+	}
+}
+
+func TestPartialSnapshotPassesCoverage(t *testing.T) {
+	config.AppConfig = &config.Config{}
+	config.AppConfig.ExchangeRate.Validation.MinCoverage = 0.9
+	config.AppConfig.ExchangeRate.Validation.MaxChangePct = 20
+
+	// 上一份快照覆盖的货币远多于 ECB
+	previous := &BaseSnapshot{Source: "exchangerate-api", Date: SnapshotDate(time.Now()), Rates: map[string]float64{
+		"EUR": 0.91, "CNY": 7.1, "JPY": 141, "GBP": 0.79, "VND": 24300, "THB": 34.5, "KRW": 1300, "INR": 83,
+	}}
+	provider := newTestECBProvider(t, ecbServer(t))
+	snapshot, err := FetchFromProviders(context.Background(), []RateProvider{provider}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reasons := ValidateSnapshot(snapshot, previous); len(reasons) == 0 {
+		t.Error("unmerged partial snapshot should fail coverage")
+	}
+	if filled := snapshot.FillMissing(previous); filled != 4 {
+		t.Errorf("filled %d currencies, want 4", filled)
+	}
+	if reasons := ValidateSnapshot(snapshot, previous); len(reasons) > 0 {
+		t.Errorf("merged snapshot rejected: %v", reasons)
+	}
+	assertRate(t, snapshot.Rates, "VND", 24300)
+	assertRate(t, snapshot.Rates, "CNY", 7.8067/1.0956)
+}
+
+func TestFetchFromProvidersFallsThroughOnRejection(t *testing.T) {
+	first := jsonServer(t, http.StatusOK, `{"rates":{"CNY":70}}`, nil)
+	second := jsonServer(t, http.StatusOK, `{"rates":{"CNY":7.1}}`, nil)
+	providers := []RateProvider{
+		newTestProvider(t, config.RateProviderConfig{Name: "first", Type: "http-json", URL: first.URL, Base: "USD", RatesPath: "rates"}),
+		newTestProvider(t, config.RateProviderConfig{Name: "second", Type: "http-json", URL: second.URL, Base: "USD", RatesPath: "rates"}),
+	}
+
+	var rejected []string
+	snapshot, err := FetchFromProviders(context.Background(), providers, func(s *RateSnapshot) error {
+		if s.Rates["CNY"] > 10 {
+			rejected = append(rejected, s.Provider)
+			return ErrSnapshotQuarantined
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FetchFromProviders: %v", err)
+	}
+	if snapshot.Provider != "second" || len(rejected) != 1 || rejected[0] != "first" {
+		t.Errorf("provider = %s, rejected = %v", snapshot.Provider, rejected)
+	}
+
+	_, err = FetchFromProviders(context.Background(), providers[:1], func(*RateSnapshot) error { return ErrSnapshotQuarantined })
+	if !errors.Is(err, ErrSnapshotQuarantined) {
+		t.Errorf("err = %v, want ErrSnapshotQuarantined", err)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"time"
+)
+
+// RatePoint 某一天的交叉汇率
+type RatePoint struct {
+	Date time.Time `json:"date"`
+	Rate float64   `json:"rate"`
+}
+
+// SnapshotDate 将时间截断到当天零点，作为快照的日期键
+func SnapshotDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// CrossRate 通过 USD 基准计算交叉汇率
+// 公式: Rate(From -> To) = Rate(USD -> To) / Rate(USD -> From)
+func CrossRate(usdToFrom, usdToTo float64) (float64, error) {
+	if usdToFrom <= 0 || usdToTo <= 0 {
+		return 0, fmt.Errorf("invalid base rate: %v / %v", usdToTo, usdToFrom)
+	}
+	return usdToTo / usdToFrom, nil
+}
+
+// GetCrossRateHistory 从历史快照中的 USD 腿计算 [start, end] 区间内每天的交叉汇率
+// 某天缺少任一条腿时跳过该天
+func GetCrossRateHistory(from, to string, start, end time.Time) ([]RatePoint, error) {
+	var legs []models.ExchangeRate
+	if err := global.Db.
+		Where("from_currency = ? AND to_currency IN ? AND date BETWEEN ? AND ?", "USD", []string{from, to}, SnapshotDate(start), SnapshotDate(end)).
+		Order("date ASC").
+		Find(&legs).Error; err != nil {
+		return nil, err
+	}
+
+	// 按日期分组：date -> currency -> rate
+	byDate := make(map[time.Time]map[string]float64)
+	var dates []time.Time
+	for _, leg := range legs {
+		day := SnapshotDate(leg.Date)
+		if _, ok := byDate[day]; !ok {
+			byDate[day] = map[string]float64{"USD": 1}
+			dates = append(dates, day)
+		}
+		byDate[day][leg.ToCurrency] = leg.Rate
+	}
+
+	points := make([]RatePoint, 0, len(dates))
+	for _, day := range dates {
+		usdToFrom, okFrom := byDate[day][from]
+		usdToTo, okTo := byDate[day][to]
+		if !okFrom || !okTo {
+			continue
+		}
+		rate, err := CrossRate(usdToFrom, usdToTo)
+		if err != nil {
+			continue
+		}
+		points = append(points, RatePoint{Date: day, Rate: rate})
+	}
+	return points, nil
+}
+
+// FindHistoricalProvider 在已配置的数据源中查找支持历史回填的数据源
+func FindHistoricalProvider(name string) (HistoricalRateProvider, error) {
+	for _, p := range rateProviders {
+		if p.Name() != name {
+			continue
+		}
+		hp, ok := p.(HistoricalRateProvider)
+		if !ok {
+			return nil, fmt.Errorf("provider %s does not support history backfill", name)
+		}
+		return hp, nil
+	}
+	return nil, fmt.Errorf("provider %s is not configured", name)
+}
+
+// BackfillHistory 从数据源拉取全部历史快照，换算为 USD 基准后写入历史表，返回写入的天数
+// 已存在的同日数据会被覆盖，写入过数据时 K 线与统计缓存随之失效
+func BackfillHistory(ctx context.Context, provider HistoricalRateProvider) (days int, err error) {
+	snapshots, err := provider.FetchHistory(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// 中途失败时已写入的部分同样生效；ctx 可能已取消，缓存清理不受其影响
+	defer func() {
+		if days > 0 {
+			refreshDerivedRateCaches(context.Background())
+		}
+	}()
+
+	for _, snapshot := range snapshots {
+		if err := ctx.Err(); err != nil {
+			return days, err
+		}
+		if snapshot.UpdatedAt.IsZero() {
+			continue
+		}
+		if err := snapshot.ToUSDBase(); err != nil {
+			return days, fmt.Errorf("%s: %w", snapshot.UpdatedAt.Format("2006-01-02"), err)
+		}
+		snapshot.Provider = provider.Name()
+		if err := saveSnapshotHistory(global.Db, snapshot, SnapshotDate(snapshot.UpdatedAt), time.Now()); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, nil
+}
@@ -2,137 +2,306 @@ package services
 
 import (
 	"context"
-	"encoding/json" // 假设您有 config 包，否则请使用环境变量或常量
+	"errors"
+	"exchangeapp/config"
 	"exchangeapp/global"
 	"exchangeapp/models"
 	"fmt"
 	"log"
-	"net/http"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
-	// 建议将 Key 放入 config.yaml 或环境变量
-	ExchangeAPIKey       = "acbe932332b25dafdab979b5"
-	ExchangeBaseURL      = "https://v6.exchangerate-api.com/v6/%s/latest/USD"
-	ExchangeRateRedisKey = "rates:usd_base" // 使用 Hash 存储所有汇率
+	ExchangeRateRedisKey      = "rates:usd_base"         // 使用 Hash 存储所有汇率
+	ExchangeRateMetaRedisKey  = "rates:usd_base:meta"    // 当前快照的元信息 (source, fetchedAt)
+	RateSchedulerStatusKey    = "rates:scheduler:status" // 调度状态，所有副本共享
+	RateSchedulerStatusExpire = 30 * 24 * time.Hour
+	RefreshTriggerStartup     = "startup"
+	RefreshTriggerSchedule    = "schedule"
+	RefreshTriggerManual      = "manual"
+
+	RateSchedulerLeaderLock = "rates:scheduler" // 选举 leader 的租约，只有 leader 运行定时任务
+	RateSchedulerLeaseTTL   = 30 * time.Second
+	RateRefreshLock         = "rates:refresh" // 单次刷新的互斥锁
+	RateRefreshLeaseTTL     = time.Minute
 )
 
-// FetchLatestRates 拉取 USD 基准汇率
-func FetchLatestRates() (map[string]float64, error) {
-	// 使用 config 包中的配置，如果没有则回退到常量
-	// apiKey := config.AppConfig.ExchangeAPIKey
-	apiKey := ExchangeAPIKey
+var ErrRefreshInProgress = errors.New("rate refresh already in progress")
 
-	url := fmt.Sprintf(ExchangeBaseURL, apiKey)
+var (
+	// 按配置顺序排列的数据源，在 StartExchangeRateScheduler 中初始化
+	rateProviders []RateProvider
+	rateSchedule  Schedule
+)
 
-	// 设置 HTTP Client 超时，防止请求挂起
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// RefreshResult 一次刷新的结果
+type RefreshResult struct {
+	Provider   string        `json:"provider"`
+	Currencies int           `json:"currencies"`
+	Attempts   int           `json:"attempts"`
+	Duration   time.Duration `json:"duration"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status: %d", resp.StatusCode)
-	}
+// SchedulerStatus 调度状态，供管理后台查看
+type SchedulerStatus struct {
+	Schedule      string     `json:"schedule"`
+	LastRunAt     *time.Time `json:"lastRunAt"`
+	LastTrigger   string     `json:"lastTrigger"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	LastErrorAt   *time.Time `json:"lastErrorAt"`
+	LastError     string     `json:"lastError"`
+	NextRunAt     *time.Time `json:"nextRunAt"`
+	Provider      string     `json:"provider"`
+}
 
-	var result struct {
-		Result          string             `json:"result"`
-		ConversionRates map[string]float64 `json:"conversion_rates"`
-	}
+// FetchLatestRates 依次尝试已配置的数据源，拉取 USD 基准汇率，accept 见 FetchFromProviders
+func FetchLatestRates(ctx context.Context, accept func(*RateSnapshot) error) (*RateSnapshot, error) {
+	return FetchFromProviders(ctx, rateProviders, accept)
+}
+
+// StartExchangeRateScheduler 启动汇率定时任务
+// 建议在 main.go 中传入 context 以便优雅关闭
+func StartExchangeRateScheduler(ctx context.Context) {
+	cfg := config.AppConfig.ExchangeRate
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("json decode failed: %w", err)
+	providers, errs := NewRateProviders(cfg.Providers, cfg.Timeout)
+	for _, err := range errs {
+		log.Printf("Skipping rate provider: %v\n", err)
 	}
+	rateProviders = providers
 
-	if result.Result != "success" {
-		return nil, fmt.Errorf("API error: %s", result.Result)
+	schedule, err := NewSchedule(cfg.Cron, cfg.Interval)
+	if err != nil {
+		log.Printf("Invalid rate schedule, falling back to every 24h: %v\n", err)
+		schedule = intervalSchedule(24 * time.Hour)
 	}
+	rateSchedule = schedule
 
-	return result.ConversionRates, nil
+	// 多副本部署时只有 leader 运行定时任务，leader 宕机后由其他副本接管
+	go RunAsLeader(ctx, RateSchedulerLeaderLock, RateSchedulerLeaseTTL, runRateSchedule)
 }
 
-// StartExchangeRateScheduler 启动汇率定时任务
-// 建议在 main.go 中传入 context 以便优雅关闭
-func StartExchangeRateScheduler(ctx context.Context) {
-	// 立即执行一次
-	log.Println("Starting initial exchange rate update...")
-	if err := updateRates(); err != nil {
-		log.Printf("Initial rate update failed: %v\n", err)
-	}
-
-	// 定时器：每 24 小时执行一次
-	ticker := time.NewTicker(24 * time.Hour)
-
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				log.Println("Scheduled update triggered.")
-				if err := updateRates(); err != nil {
-					log.Printf("Scheduled rate update failed: %v\n", err)
-				}
-			case <-ctx.Done():
-				log.Println("Stopping exchange rate scheduler...")
-				return
+// runRateSchedule leader 上运行的调度循环，失去 leader 身份时 ctx 被取消
+func runRateSchedule(ctx context.Context) {
+	// 成为 leader 时若已错过一次计划执行（或从未成功过），立即补跑一次
+	if status, err := GetSchedulerStatus(ctx); err != nil || status.LastSuccessAt == nil ||
+		!rateSchedule.Next(*status.LastSuccessAt).After(time.Now()) {
+		log.Println("Starting initial exchange rate update...")
+		if _, err := RefreshRates(ctx, RefreshTriggerStartup); err != nil {
+			log.Printf("Initial rate update failed: %v\n", err)
+		}
+	}
+
+	for {
+		next := rateSchedule.Next(time.Now())
+		if next.IsZero() {
+			log.Println("Rate schedule has no next run, scheduler stopped.")
+			return
+		}
+		setSchedulerStatus(ctx, map[string]interface{}{"nextRunAt": next.Format(time.RFC3339)})
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			log.Println("Scheduled update triggered.")
+			if _, err := RefreshRates(ctx, RefreshTriggerSchedule); err != nil {
+				log.Printf("Scheduled rate update failed: %v\n", err)
 			}
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Stopping exchange rate scheduler...")
+			return
 		}
-	}()
+	}
 }
 
-// updateRates 核心逻辑：获取 -> 存DB -> 存Redis
-func updateRates() error {
-	ratesMap, err := FetchLatestRates()
-	if err != nil {
+// RefreshRates 执行一次带重试的汇率更新并记录调度状态，定时任务和管理员手动刷新共用
+func RefreshRates(ctx context.Context, trigger string) (*RefreshResult, error) {
+	var result *RefreshResult
+	// 集群内同时只允许一次刷新（leader 的定时任务与任意副本上的手动刷新可能重叠）
+	err := WithLeaseLock(ctx, RateRefreshLock, RateRefreshLeaseTTL, func(ctx context.Context) error {
+		var err error
+		result, err = refreshRates(ctx, trigger)
 		return err
+	})
+	if errors.Is(err, ErrLockHeld) {
+		return nil, ErrRefreshInProgress
 	}
+	return result, err
+}
 
-	now := time.Now()
-	var rates []models.ExchangeRate
+func refreshRates(ctx context.Context, trigger string) (*RefreshResult, error) {
+	retry := config.AppConfig.ExchangeRate.Retry
+	maxAttempts := retry.MaxAttempts
+	// 手动刷新由管理员同步等待结果，只尝试一轮数据源链，不做退避重试
+	if maxAttempts < 1 || trigger == RefreshTriggerManual {
+		maxAttempts = 1
+	}
+	backoff := retry.InitialBackoff
 
-	// 1. 转换为 DB 模型
-	// 优化策略：只存储 USD -> Any 的汇率 (约 160 条)，而不是 Any -> Any (25600 条)
-	// 前端或其他服务计算 A -> B 时，公式为: (USD->B) / (USD->A)
-	for code, rate := range ratesMap {
-		rates = append(rates, models.ExchangeRate{
-			FromCurrency: "USD",
-			ToCurrency:   code,
-			Rate:         rate,
-			Date:         now,
+	start := time.Now()
+	setSchedulerStatus(ctx, map[string]interface{}{
+		"lastRunAt":   start.Format(time.RFC3339),
+		"lastTrigger": trigger,
+	})
+
+	var snapshot *RateSnapshot
+	var err error
+	attempts := 0
+	for attempts < maxAttempts {
+		attempts++
+		if snapshot, err = updateRates(ctx); err == nil {
+			break
+		}
+		// 首次启动且数据源不可用时先导入内置快照，保证服务可用，之后的重试成功会覆盖它
+		if attempts == 1 && trigger == RefreshTriggerStartup {
+			seedIfEmpty(ctx)
+		}
+		// 被隔离的快照需要人工审核，重试只会重复隔离
+		if attempts == maxAttempts || errors.Is(err, ErrSnapshotQuarantined) {
+			break
+		}
+		log.Printf("Rate update attempt %d/%d failed, retrying in %s: %v\n", attempts, maxAttempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			err = ctx.Err()
+			attempts = maxAttempts
+		}
+		if backoff *= 2; retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+
+	if err != nil {
+		setSchedulerStatus(context.Background(), map[string]interface{}{
+			"lastErrorAt": time.Now().Format(time.RFC3339),
+			"lastError":   err.Error(),
 		})
+		return nil, err
+	}
+
+	setSchedulerStatus(ctx, map[string]interface{}{
+		"lastSuccessAt": time.Now().Format(time.RFC3339),
+		"provider":      snapshot.Provider,
+	})
+	return &RefreshResult{
+		Provider:   snapshot.Provider,
+		Currencies: len(snapshot.Rates),
+		Attempts:   attempts,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// GetSchedulerStatus 读取调度状态
+func GetSchedulerStatus(ctx context.Context) (*SchedulerStatus, error) {
+	fields, err := global.RedisDB.HGetAll(ctx, RateSchedulerStatusKey).Result()
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. 数据库事务更新
-	err = global.Db.Transaction(func(tx *gorm.DB) error {
-		// 清空旧汇率表 (如果是只存最新汇率的策略)
-		// 或者您可以选择保留历史记录，那样就不需要 Delete，只需 Insert
-		if err := tx.Exec("DELETE FROM exchange_rates").Error; err != nil {
-			return err
+	parseTime := func(key string) *time.Time {
+		t, err := time.Parse(time.RFC3339, fields[key])
+		if err != nil {
+			return nil
 		}
+		return &t
+	}
 
-		// 批量插入 (GORM v2 支持批量插入，性能很好)
-		if len(rates) > 0 {
-			// 分批次插入，防止 SQL 语句过长（虽然 160 条一次插入没问题）
-			if err := tx.CreateInBatches(rates, 100).Error; err != nil {
-				return err
-			}
+	status := &SchedulerStatus{
+		LastRunAt:     parseTime("lastRunAt"),
+		LastTrigger:   fields["lastTrigger"],
+		LastSuccessAt: parseTime("lastSuccessAt"),
+		LastErrorAt:   parseTime("lastErrorAt"),
+		LastError:     fields["lastError"],
+		NextRunAt:     parseTime("nextRunAt"),
+		Provider:      fields["provider"],
+	}
+	if rateSchedule != nil {
+		status.Schedule = rateSchedule.String()
+	}
+	return status, nil
+}
+
+func setSchedulerStatus(ctx context.Context, fields map[string]interface{}) {
+	pipe := global.RedisDB.Pipeline()
+	pipe.HSet(ctx, RateSchedulerStatusKey, fields)
+	pipe.Expire(ctx, RateSchedulerStatusKey, RateSchedulerStatusExpire)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Saving scheduler status failed: %v\n", err)
+	}
+}
+
+// updateRates 核心逻辑：获取 -> 校验 -> 存DB -> 存Redis
+func updateRates(ctx context.Context) (*RateSnapshot, error) {
+	// 上一份快照：用于校验新数据，以及计算推送给客户端的增量
+	previous, err := LoadBaseSnapshot(ctx)
+	if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+		return nil, err
+	}
+	now := time.Now()
+
+	// 校验不通过的快照进入隔离区等待管理员审核，并继续尝试下一个数据源；全部不通过时继续使用上一份快照
+	snapshot, err := FetchLatestRates(ctx, func(snapshot *RateSnapshot) error {
+		if snapshot.Partial {
+			snapshot.FillMissing(previous)
 		}
-		return nil
+		reasons := ValidateSnapshot(snapshot, previous)
+		if len(reasons) == 0 {
+			return nil
+		}
+		q, err := QuarantineSnapshot(snapshot, now, reasons)
+		if err != nil {
+			return fmt.Errorf("quarantine failed: %w", err)
+		}
+		return fmt.Errorf("%w (id=%d): %s", ErrSnapshotQuarantined, q.ID, strings.Join(reasons, "; "))
 	})
 	if err != nil {
+		return nil, err
+	}
+
+	if err := applySnapshot(ctx, snapshot, previous, now); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// applySnapshot 将快照写入历史表和 Redis，并触发推送、缓存失效和提醒检查
+func applySnapshot(ctx context.Context, snapshot *RateSnapshot, previous *BaseSnapshot, now time.Time) error {
+	// 1. 写入历史表
+	if err := saveSnapshotHistory(global.Db, snapshot, SnapshotDate(now), now); err != nil {
 		return fmt.Errorf("db update failed: %w", err)
 	}
 
-	// 3. Redis 缓存优化
+	// 2. 写入 Redis 并通知各副本
+	if err := publishSnapshot(ctx, snapshot, previous, now); err != nil {
+		return err
+	}
+
+	// 3. 检查用户汇率提醒，失败不影响本次更新结果
+	current := &BaseSnapshot{Rates: snapshot.Rates, Source: snapshot.Provider, Date: SnapshotDate(now), FetchedAt: now, UpdatedAt: snapshot.UpdatedAt}
+	if err := EvaluateRateAlerts(ctx, current, now); err != nil {
+		log.Printf("Rate alert evaluation failed: %v\n", err)
+	}
+
+	log.Printf("Exchange rates updated successfully from %s. Total currencies: %d\n", snapshot.Provider, len(snapshot.Rates))
+	return nil
+}
+
+// publishSnapshot 将快照设为当前生效的 Redis 基准 Hash，推送增量并使派生缓存失效
+func publishSnapshot(ctx context.Context, snapshot *RateSnapshot, previous *BaseSnapshot, fetchedAt time.Time) error {
+	ratesMap := snapshot.Rates
+
+	// Redis 缓存优化
 	// 使用 HSET 一次性写入所有汇率到 Hash 表中，避免成千上万个 Key
 	// Key: "rates:usd_base", Field: "CNY", Value: "7.25"
 	pipe := global.RedisDB.Pipeline()
-	pipe.Del(context.Background(), ExchangeRateRedisKey) // 清除旧 Hash
+	pipe.Del(ctx, ExchangeRateRedisKey) // 清除旧 Hash
 
 	// 将 map[string]float64 转换为 map[string]interface{} 以适配 Redis HDel/HSet
 	fields := make(map[string]interface{})
@@ -140,13 +309,75 @@ func updateRates() error {
 		fields[code] = rate
 	}
 
-	pipe.HMSet(context.Background(), ExchangeRateRedisKey, fields)
-	pipe.Expire(context.Background(), ExchangeRateRedisKey, 25*time.Hour) // 设置过期时间略大于更新间隔
+	// 不设置过期时间：刷新连续失败时继续提供最后一份快照，由响应中的 stale 标记提示数据过旧
+	pipe.HMSet(ctx, ExchangeRateRedisKey, fields)
+
+	// 记录本次快照的数据源、抓取时间以及数据源声明的更新时间
+	meta := map[string]interface{}{
+		"source":    snapshot.Provider,
+		"fetchedAt": fetchedAt.Format(time.RFC3339),
+	}
+	if !snapshot.UpdatedAt.IsZero() {
+		meta["updatedAt"] = snapshot.UpdatedAt.Format(time.RFC3339)
+	}
+	pipe.Del(ctx, ExchangeRateMetaRedisKey)
+	pipe.HSet(ctx, ExchangeRateMetaRedisKey, meta)
 
-	if _, err := pipe.Exec(context.Background()); err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("redis pipeline failed: %w", err)
 	}
 
-	log.Printf("Exchange rates updated successfully. Total currencies: %d\n", len(rates))
+	// 通过 Redis pub/sub 通知所有副本推送增量
+	var previousRates map[string]float64
+	if previous != nil {
+		previousRates = previous.Rates
+	}
+	if err := PublishRateUpdate(ctx, previousRates, ratesMap, snapshot.Provider, fetchedAt); err != nil {
+		log.Printf("Publishing rate update failed: %v\n", err)
+	}
+
+	refreshDerivedRateCaches(ctx)
 	return nil
 }
+
+// refreshDerivedRateCaches 历史数据变化后派生数据缓存失效，并预先计算常用货币对的统计指标
+func refreshDerivedRateCaches(ctx context.Context) {
+	clearCacheByPattern(ctx, CandleCachePrefix+"*")
+	PrecomputeRateStats(ctx)
+}
+
+// saveSnapshotHistory 将 USD 基准快照写入历史表
+// 每天保留一份快照：(from, to, date) 唯一，同一天多次写入只覆盖当天的值，历史数据不会被清空
+// fetchedAt 为本服务抓取的时间，与数据源声明的更新时间一并保存
+func saveSnapshotHistory(db *gorm.DB, snapshot *RateSnapshot, date, fetchedAt time.Time) error {
+	// 优化策略：只存储 USD -> Any 的汇率 (约 160 条)，而不是 Any -> Any (25600 条)
+	// 前端或其他服务计算 A -> B 时，公式为: (USD->B) / (USD->A)
+	var providerUpdatedAt *time.Time
+	if !snapshot.UpdatedAt.IsZero() {
+		providerUpdatedAt = &snapshot.UpdatedAt
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(snapshot.Rates))
+	for code, rate := range snapshot.Rates {
+		rates = append(rates, models.ExchangeRate{
+			FromCurrency:      "USD",
+			ToCurrency:        code,
+			Rate:              rate,
+			Date:              date,
+			Source:            snapshot.Provider,
+			ProviderUpdatedAt: providerUpdatedAt,
+			FetchedAt:         &fetchedAt,
+		})
+	}
+	if len(rates) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 分批次插入，防止 SQL 语句过长
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "provider_updated_at", "fetched_at"}),
+		}).CreateInBatches(rates, 100).Error
+	})
+}
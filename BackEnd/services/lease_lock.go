@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"exchangeapp/global"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const LeaseLockPrefix = "locks:"
+
+var ErrLockHeld = errors.New("lock is held by another instance")
+
+// 仅当锁仍属于自己（token 一致）时才续期 / 释放，防止误删其他实例的锁
+var (
+	renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// LeaseLock 基于 Redis 的租约锁：SET NX PX 加锁，持有者需在 TTL 内续期，进程崩溃后租约自动过期
+type LeaseLock struct {
+	key   string
+	token string
+	ttl   time.Duration
+}
+
+// NewLeaseLock 创建租约锁，每个实例使用独立的随机 token
+func NewLeaseLock(name string, ttl time.Duration) *LeaseLock {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return &LeaseLock{key: LeaseLockPrefix + name, token: hex.EncodeToString(buf), ttl: ttl}
+}
+
+// TryAcquire 尝试获取锁，已被他人持有时返回 false
+func (l *LeaseLock) TryAcquire(ctx context.Context) (bool, error) {
+	return global.RedisDB.SetNX(ctx, l.key, l.token, l.ttl).Result()
+}
+
+// Renew 续期，锁已过期或被他人持有时返回 false
+func (l *LeaseLock) Renew(ctx context.Context) (bool, error) {
+	n, err := renewLeaseScript.Run(ctx, global.RedisDB, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release 释放自己持有的锁
+func (l *LeaseLock) Release(ctx context.Context) error {
+	return releaseLeaseScript.Run(ctx, global.RedisDB, []string{l.key}, l.token).Err()
+}
+
+// keepAlive 每 TTL/3 续期一次，续期失败时调用 lost 并退出
+func (l *LeaseLock) keepAlive(ctx context.Context, lost func()) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ok, err := l.Renew(ctx)
+			if err != nil || !ok {
+				if ctx.Err() == nil {
+					log.Printf("Lease %s lost: ok=%v err=%v\n", l.key, ok, err)
+					lost()
+				}
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WithLeaseLock 持有锁期间执行 fn，锁被他人持有时立即返回 ErrLockHeld
+// fn 执行期间自动续期，一旦失去租约 fn 的 ctx 会被取消
+func WithLeaseLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lock := NewLeaseLock(name, ttl)
+	ok, err := lock.TryAcquire(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockHeld
+	}
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go lock.keepAlive(fnCtx, cancel)
+	defer lock.Release(context.Background())
+
+	return fn(fnCtx)
+}
+
+// RunAsLeader 参与名为 name 的选举，成为 leader 后运行 job 直到 ctx 取消或失去租约；
+// 失去租约时取消 job 的 ctx，等待其返回后重新参与选举。leader 宕机后其他实例最迟在 ttl 后接管。
+// job 在 ctx 未取消时自行返回表示任务已结束，此时释放租约并退出选举，不再重复运行
+func RunAsLeader(ctx context.Context, name string, ttl time.Duration, job func(ctx context.Context)) {
+	lock := NewLeaseLock(name, ttl)
+	retry := time.NewTicker(ttl / 3)
+	defer retry.Stop()
+
+	for {
+		ok, err := lock.TryAcquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Leader election %s failed: %v\n", name, err)
+		}
+
+		if ok {
+			log.Printf("Became leader of %s\n", name)
+			jobCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			finished := false // job 在 jobCtx 取消前自行返回
+			go func() {
+				defer close(done)
+				defer cancel() // job 自行退出时同样让出 leader
+				job(jobCtx)
+				finished = jobCtx.Err() == nil
+			}()
+
+			lock.keepAlive(jobCtx, cancel)
+			cancel()
+			<-done
+			_ = lock.Release(context.Background())
+			log.Printf("Stepped down as leader of %s\n", name)
+
+			if finished && ctx.Err() == nil {
+				log.Printf("Job of %s finished, leaving election\n", name)
+				return
+			}
+		}
+
+		select {
+		case <-retry.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
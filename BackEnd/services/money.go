@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// RoundingMode 金额舍入方式
+type RoundingMode string
+
+const (
+	RoundHalfEven RoundingMode = "half-even" // 银行家舍入
+	RoundHalfUp   RoundingMode = "half-up"   // 四舍五入
+
+	// RatePrecision 返回给客户端的汇率保留的小数位数；换算金额使用未舍入的精确汇率，
+	// 避免 IRR -> KWD 这类很小的交叉汇率丢失有效数字
+	RatePrecision = 10
+)
+
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// MinorUnits 返回货币的最小单位小数位数 (ISO 4217 minor units)，未登记的货币默认 2 位
+func MinorUnits(code string) int {
+	if c, ok := LookupCurrency(code); ok {
+		return c.MinorUnits
+	}
+	return 2
+}
+
+// ParseRoundingMode 解析舍入方式，为空时默认银行家舍入
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch RoundingMode(s) {
+	case "", RoundHalfEven:
+		return RoundHalfEven, nil
+	case RoundHalfUp:
+		return RoundHalfUp, nil
+	}
+	return "", fmt.Errorf("unsupported rounding mode: %s", s)
+}
+
+// ParseDecimal 精确解析十进制字符串（不经过 float64）
+func ParseDecimal(s string) (*big.Rat, error) {
+	if !decimalPattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	return r, nil
+}
+
+// RatFromFloat 将数据源给出的 float64 汇率按其最短十进制表示转换为精确有理数
+func RatFromFloat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// RoundRat 按指定小数位数和舍入方式舍入
+func RoundRat(r *big.Rat, places int, mode RoundingMode) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+
+	// 向零截断，再根据余数决定是否进位
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	twiceRem := new(big.Int).Abs(rem)
+	twiceRem.Lsh(twiceRem, 1)
+
+	awayFromZero := false
+	switch twiceRem.Cmp(scaled.Denom()) {
+	case 1:
+		awayFromZero = true
+	case 0:
+		awayFromZero = mode == RoundHalfUp || q.Bit(0) == 1
+	}
+	if awayFromZero {
+		if scaled.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// Conversion 一次金额换算的结果，金额与汇率均为十进制字符串
+// Rate 为按 Side 选用的汇率 (中间价 / 买入价 / 卖出价)，ConvertedAmount = Amount × Rate
+type Conversion struct {
+	From            string       `json:"from"`
+	To              string       `json:"to"`
+	Amount          string       `json:"amount"`
+	ConvertedAmount string       `json:"convertedAmount"`
+	Rate            string       `json:"rate"`
+	Side            QuoteSide    `json:"side"`
+	Mid             string       `json:"mid"`
+	Bid             string       `json:"bid"`
+	Ask             string       `json:"ask"`
+	Spread          Spread       `json:"spread"`
+	Rounding        RoundingMode `json:"rounding"`
+	MinorUnits      int          `json:"minorUnits"`
+	Timestamp       time.Time    `json:"timestamp"`            // 所用快照的抓取时间
+	AsOf            time.Time    `json:"asOf"`                 // 数据实际对应的时间
+	Source          string       `json:"source,omitempty"`     // 提供快照的数据源
+	Stale           bool         `json:"stale"`                // 当前快照超过 staleAfter 未刷新
+	Overridden      bool         `json:"overridden,omitempty"` // 使用了管理员人工汇率
+}
+
+// formatRate 汇率舍入到 RatePrecision 位后输出
+func formatRate(r *big.Rat) string {
+	return RoundRat(r, RatePrecision, RoundHalfEven).FloatString(RatePrecision)
+}
+
+// ConvertAmount 用 USD 基准的两条腿换算金额
+// 用精确的交叉汇率 (中间价) 按点差展开买入价 / 卖出价，再用 side 对应的汇率乘以金额并舍入到目标货币的最小单位，
+// 返回的汇率字符串舍入到 RatePrecision 位
+func ConvertAmount(from, to, amountStr string, usdToFrom, usdToTo float64, spread Spread, side QuoteSide, mode RoundingMode) (*Conversion, error) {
+	amount, err := ParseDecimal(amountStr)
+	if err != nil {
+		return nil, err
+	}
+	if usdToFrom <= 0 || usdToTo <= 0 {
+		return nil, errors.New("invalid base rate")
+	}
+
+	mid := new(big.Rat).Quo(RatFromFloat(usdToTo), RatFromFloat(usdToFrom))
+	bid, ask := spread.QuoteRat(mid)
+
+	rate := mid
+	switch side {
+	case SideBuy:
+		rate = ask
+	case SideSell:
+		rate = bid
+	default:
+		side = SideMid
+	}
+
+	units := MinorUnits(to)
+	converted := RoundRat(new(big.Rat).Mul(amount, rate), units, mode)
+
+	return &Conversion{
+		From:            from,
+		To:              to,
+		Amount:          amountStr,
+		ConvertedAmount: converted.FloatString(units),
+		Rate:            formatRate(rate),
+		Side:            side,
+		Mid:             formatRate(mid),
+		Bid:             formatRate(bid),
+		Ask:             formatRate(ask),
+		Spread:          spread,
+		Rounding:        mode,
+		MinorUnits:      units,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"exchangeapp/models"
+	"testing"
+)
+
+func TestConvertAmountSmallCrossRate(t *testing.T) {
+	// IRR -> KWD 的交叉汇率约 7.3e-6，舍入到 10 位小数后只剩 5 位有效数字
+	c, err := ConvertAmount("IRR", "KWD", "1000000000", 42000, 0.3075, Spread{Unit: models.SpreadUnitBps}, SideMid, RoundHalfEven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ConvertedAmount != "7321.429" {
+		t.Errorf("ConvertedAmount = %s, want 7321.429", c.ConvertedAmount)
+	}
+	if c.Mid != "0.0000073214" {
+		t.Errorf("Mid = %s, want 0.0000073214", c.Mid)
+	}
+
+	// 点差作用于精确汇率：100bps 时卖出价为中间价的 99.5%
+	c, err = ConvertAmount("IRR", "KWD", "1000000000", 42000, 0.3075, Spread{Value: 100, Unit: models.SpreadUnitBps}, SideSell, RoundHalfEven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ConvertedAmount != "7284.821" {
+		t.Errorf("sell ConvertedAmount = %s, want 7284.821", c.ConvertedAmount)
+	}
+}
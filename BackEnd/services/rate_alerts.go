@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EvaluateRateAlerts 在每次汇率更新成功后批量检查所有启用的提醒，
+// 满足条件且已过冷却期的提醒写入通知表；一次性提醒触发后自动停用
+func EvaluateRateAlerts(ctx context.Context, current *BaseSnapshot, now time.Time) error {
+	// 涨跌幅类提醒需要前一天的快照，缺失时这类提醒本轮跳过
+	previous, err := LoadSnapshotAsOf(SnapshotDate(now).AddDate(0, 0, -1))
+	if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+		return err
+	}
+
+	var alerts []models.RateAlert
+	return global.Db.WithContext(ctx).Where("active = ?", true).FindInBatches(&alerts, 500, func(tx *gorm.DB, batch int) error {
+		var notifications []models.AlertNotification
+		var triggered []models.RateAlert
+
+		for _, alert := range alerts {
+			if alert.LastTriggeredAt != nil &&
+				now.Sub(*alert.LastTriggeredAt) < time.Duration(alert.CooldownMinutes)*time.Minute {
+				continue
+			}
+
+			rate, ok := pairRate(current, alert.FromCurrency, alert.ToCurrency)
+			if !ok {
+				continue
+			}
+
+			var message string
+			switch alert.Condition {
+			case models.AlertAbove:
+				if rate > alert.Threshold {
+					message = fmt.Sprintf("%s/%s 当前汇率 %.4f，已高于设定的 %.4f", alert.FromCurrency, alert.ToCurrency, rate, alert.Threshold)
+				}
+			case models.AlertBelow:
+				if rate < alert.Threshold {
+					message = fmt.Sprintf("%s/%s 当前汇率 %.4f，已低于设定的 %.4f", alert.FromCurrency, alert.ToCurrency, rate, alert.Threshold)
+				}
+			case models.AlertChangePct:
+				if previous == nil {
+					continue
+				}
+				prevRate, ok := pairRate(previous, alert.FromCurrency, alert.ToCurrency)
+				if !ok {
+					continue
+				}
+				changePct := (rate/prevRate - 1) * 100
+				if math.Abs(changePct) >= alert.Threshold {
+					message = fmt.Sprintf("%s/%s 一天内变动 %+.2f%%（%.4f → %.4f），超过设定的 %.2f%%", alert.FromCurrency, alert.ToCurrency, changePct, prevRate, rate, alert.Threshold)
+				}
+			}
+			if message == "" {
+				continue
+			}
+
+			notifications = append(notifications, models.AlertNotification{
+				UserID:  alert.UserID,
+				AlertID: alert.ID,
+				Message: message,
+				Rate:    rate,
+			})
+			triggeredAt := now
+			alert.LastTriggeredAt = &triggeredAt
+			if !alert.Recurring {
+				alert.Active = false
+			}
+			triggered = append(triggered, alert)
+		}
+
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		// 通知与提醒状态在同一事务中写入，避免重复通知
+		return global.Db.Transaction(func(db *gorm.DB) error {
+			if err := db.CreateInBatches(notifications, 100).Error; err != nil {
+				return err
+			}
+			for _, alert := range triggered {
+				if err := db.Model(&models.RateAlert{}).Where("id = ?", alert.ID).Updates(map[string]interface{}{
+					"last_triggered_at": alert.LastTriggeredAt,
+					"active":            alert.Active,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}).Error
+}
+
+// pairRate 用快照中的两条 USD 腿计算交叉汇率
+func pairRate(snapshot *BaseSnapshot, from, to string) (float64, bool) {
+	usdToFrom, ok := snapshot.USDRate(from)
+	if !ok {
+		return 0, false
+	}
+	usdToTo, ok := snapshot.USDRate(to)
+	if !ok {
+		return 0, false
+	}
+	rate, err := CrossRate(usdToFrom, usdToTo)
+	return rate, err == nil
+}
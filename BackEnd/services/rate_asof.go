@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"time"
+)
+
+// MissingDayMode 按日期查询时当天没有快照的处理方式
+type MissingDayMode string
+
+const (
+	MissingDayPrevious    MissingDayMode = "previous"    // 使用之前最近一天的快照
+	MissingDayInterpolate MissingDayMode = "interpolate" // 用前后两天的快照按天数线性插值
+	MissingDayError       MissingDayMode = "error"       // 直接报错
+)
+
+var ErrNoSnapshotOnDate = errors.New("no rate snapshot on the requested date")
+
+// ParseMissingDayMode 解析处理方式，空字符串使用配置中的默认值
+func ParseMissingDayMode(s string) (MissingDayMode, error) {
+	if s == "" {
+		s = config.AppConfig.ExchangeRate.MissingDay
+	}
+	switch MissingDayMode(s) {
+	case MissingDayPrevious, MissingDayInterpolate, MissingDayError:
+		return MissingDayMode(s), nil
+	case "":
+		return MissingDayPrevious, nil
+	}
+	return "", fmt.Errorf("unknown missing day mode: %q", s)
+}
+
+// DatedSnapshot 某一日期生效的快照
+type DatedSnapshot struct {
+	*BaseSnapshot
+	Date          time.Time      // 请求的日期
+	EffectiveDate time.Time      // 实际使用的快照日期，插值时为之前的那一天
+	Method        string         // exact | previous | interpolated
+	Mode          MissingDayMode // 本次使用的处理方式
+}
+
+// LoadSnapshotOn 取 date 当天生效的 USD 基准快照，当天缺失时按 mode 处理
+// interpolate 模式下 date 晚于最新一份快照时没有可插值的终点，退化为 previous
+func LoadSnapshotOn(date time.Time, mode MissingDayMode) (*DatedSnapshot, error) {
+	day := SnapshotDate(date)
+	result := &DatedSnapshot{Date: day, Mode: mode}
+
+	exact, err := loadSnapshotWhere("date = ?", day)
+	if err == nil {
+		result.BaseSnapshot, result.EffectiveDate, result.Method = exact, day, "exact"
+		return result, nil
+	}
+	if !errors.Is(err, ErrRatesUnavailable) {
+		return nil, err
+	}
+
+	if mode == MissingDayError {
+		return nil, ErrNoSnapshotOnDate
+	}
+
+	before, err := LoadSnapshotAsOf(day)
+	if err != nil {
+		return nil, err
+	}
+	result.BaseSnapshot, result.EffectiveDate, result.Method = before, before.Date, "previous"
+	if mode == MissingDayPrevious {
+		return result, nil
+	}
+
+	after, err := loadSnapshotWhere("date = (?)",
+		global.Db.Model(&models.ExchangeRate{}).Select("MIN(date)").Where("from_currency = ? AND date > ?", "USD", day))
+	if errors.Is(err, ErrRatesUnavailable) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.BaseSnapshot = interpolateSnapshots(before, after, day)
+	result.Method = "interpolated"
+	return result, nil
+}
+
+// interpolateSnapshots 对两份快照中都存在的货币按天数线性插值 USD 基准汇率
+func interpolateSnapshots(before, after *BaseSnapshot, day time.Time) *BaseSnapshot {
+	// 按日历日计算权重，避免数据库返回的时区与请求日期不一致
+	start, end, target := civilDay(before.Date), civilDay(after.Date), civilDay(day)
+	weight := 0.0
+	if total := end.Sub(start).Hours(); total > 0 {
+		weight = target.Sub(start).Hours() / total
+	}
+
+	snapshot := &BaseSnapshot{Rates: make(map[string]float64, len(before.Rates)), Source: before.Source, Date: day, FetchedAt: after.FetchedAt}
+	for code, r0 := range before.Rates {
+		r1, ok := after.Rates[code]
+		if !ok {
+			continue
+		}
+		snapshot.Rates[code] = r0 + (r1-r0)*weight
+	}
+	return snapshot
+}
+
+// loadSnapshotWhere 按日期条件读取一整天的 USD 基准快照
+func loadSnapshotWhere(dateCond string, args ...interface{}) (*BaseSnapshot, error) {
+	var rates []models.ExchangeRate
+	if err := global.Db.Where("from_currency = ?", "USD").Where(dateCond, args...).Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, ErrRatesUnavailable
+	}
+	return snapshotFromRows(rates), nil
+}
+
+func civilDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"exchangeapp/global"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	CandleCachePrefix = "rates:candles:" // rates:candles:{FROM}/{TO}:{interval}:{start}:{end}:{points}
+	CandleCacheExpire = time.Hour
+)
+
+// Candle 一个时间桶内的开高低收
+type Candle struct {
+	Time  time.Time `json:"time"` // 桶起始时间
+	Open  float64   `json:"open"`
+	High  float64   `json:"high"`
+	Low   float64   `json:"low"`
+	Close float64   `json:"close"`
+	Count int       `json:"count"` // 桶内的快照数
+}
+
+// ParsePair 解析 "USD/CNY" 形式的货币对
+func ParsePair(pair string) (string, string, error) {
+	parts := strings.Split(strings.ToUpper(pair), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid pair: %q", pair)
+	}
+	return parts[0], parts[1], nil
+}
+
+// bucketStart 返回时间点所在桶的起始时间: 1d 按天, 1w 按周一, 1M 按月初
+func bucketStart(t time.Time, interval string) (time.Time, error) {
+	day := SnapshotDate(t)
+	switch interval {
+	case "1d":
+		return day, nil
+	case "1w":
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为 0
+		return day.AddDate(0, 0, -offset), nil
+	case "1M":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported interval: %s", interval)
+}
+
+// BuildCandles 将按日期升序排列的汇率序列聚合为 K 线
+func BuildCandles(points []RatePoint, interval string) ([]Candle, error) {
+	var candles []Candle
+	for _, p := range points {
+		start, err := bucketStart(p.Date, interval)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(candles); n > 0 && candles[n-1].Time.Equal(start) {
+			c := &candles[n-1]
+			c.High = math.Max(c.High, p.Rate)
+			c.Low = math.Min(c.Low, p.Rate)
+			c.Close = p.Rate
+			c.Count++
+			continue
+		}
+		candles = append(candles, Candle{Time: start, Open: p.Rate, High: p.Rate, Low: p.Rate, Close: p.Rate, Count: 1})
+	}
+	return candles, nil
+}
+
+// DownsampleLTTB 使用 Largest-Triangle-Three-Buckets 算法按收盘价挑选 threshold 根有代表性的 K 线
+// threshold <= 2 或数据量不超过 threshold 时原样返回
+func DownsampleLTTB(candles []Candle, threshold int) []Candle {
+	if threshold <= 2 || len(candles) <= threshold {
+		return candles
+	}
+
+	sampled := make([]Candle, 0, threshold)
+	sampled = append(sampled, candles[0])
+
+	// 除首尾外的数据均分到 threshold-2 个桶中
+	every := float64(len(candles)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// 下一个桶的平均点
+		avgStart := int(float64(i+1)*every) + 1
+		avgEnd := int(float64(i+2)*every) + 1
+		if avgEnd > len(candles) {
+			avgEnd = len(candles)
+		}
+		var avgX, avgY float64
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += float64(candles[j].Time.Unix())
+			avgY += candles[j].Close
+		}
+		if n := float64(avgEnd - avgStart); n > 0 {
+			avgX /= n
+			avgY /= n
+		}
+
+		// 当前桶中与上一个选中点、下一个桶平均点构成最大三角形的点
+		rangeStart := int(float64(i)*every) + 1
+		rangeEnd := int(float64(i+1)*every) + 1
+		ax, ay := float64(candles[a].Time.Unix()), candles[a].Close
+		maxArea, next := -1.0, rangeStart
+		for j := rangeStart; j < rangeEnd; j++ {
+			area := math.Abs((ax-avgX)*(candles[j].Close-ay) - (ax-float64(candles[j].Time.Unix()))*(avgY-ay))
+			if area > maxArea {
+				maxArea, next = area, j
+			}
+		}
+		sampled = append(sampled, candles[next])
+		a = next
+	}
+
+	return append(sampled, candles[len(candles)-1])
+}
+
+// GetCandles 获取货币对 K 线，结果按 货币对 + 周期 + 区间 缓存在 Redis 中，汇率刷新后失效
+func GetCandles(ctx context.Context, from, to, interval string, start, end time.Time, points int) ([]Candle, error) {
+	if _, err := bucketStart(start, interval); err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("%s%s/%s:%s:%s:%s:%d", CandleCachePrefix, from, to, interval,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), points)
+	if cached, err := global.RedisDB.Get(ctx, cacheKey).Result(); err == nil {
+		var candles []Candle
+		if json.Unmarshal([]byte(cached), &candles) == nil {
+			return candles, nil
+		}
+	}
+
+	series, err := GetCrossRateHistory(from, to, start, end)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := BuildCandles(series, interval)
+	if err != nil {
+		return nil, err
+	}
+	candles = DownsampleLTTB(candles, points)
+
+	if data, err := json.Marshal(candles); err == nil {
+		global.RedisDB.Set(ctx, cacheKey, data, CandleCacheExpire)
+	}
+	return candles, nil
+}
+
+// clearCacheByPattern 使用 SCAN 删除匹配的缓存，避免 KEYS 阻塞 Redis
+func clearCacheByPattern(ctx context.Context, pattern string) {
+	var cursor uint64
+	for {
+		keys, next, err := global.RedisDB.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return
+		}
+		if len(keys) > 0 {
+			global.RedisDB.Del(ctx, keys...)
+		}
+		if next == 0 {
+			return
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExportMaxPairs         = 20
+	DefaultExportPrecision = 6
+)
+
+// 导出格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+	ExportFormatJSON = "json"
+)
+
+// 导出布局：wide 每个货币对一列，每天一行；long 每个观测值 (日期 + 货币对) 一行
+const (
+	ExportLayoutWide = "wide"
+	ExportLayoutLong = "long"
+)
+
+// NumberFormat 数字的小数点与千分位分隔符
+type NumberFormat struct {
+	Decimal string
+	Group   string // 为空表示不分组
+}
+
+// 按语言区分的数字格式，locale 为空时输出不分组、小数点为 "." 的原始格式
+var numberFormats = map[string]NumberFormat{
+	"en": {Decimal: ".", Group: ","},
+	"zh": {Decimal: ".", Group: ","},
+	"ja": {Decimal: ".", Group: ","},
+	"ko": {Decimal: ".", Group: ","},
+	"de": {Decimal: ",", Group: "."},
+	"es": {Decimal: ",", Group: "."},
+	"it": {Decimal: ",", Group: "."},
+	"nl": {Decimal: ",", Group: "."},
+	"pt": {Decimal: ",", Group: "."},
+	"fr": {Decimal: ",", Group: " "},
+	"ru": {Decimal: ",", Group: " "},
+	"pl": {Decimal: ",", Group: " "},
+	"sv": {Decimal: ",", Group: " "},
+}
+
+// ParseNumberLocale 按 locale (如 de、de-DE、zh_CN) 的语言部分选择数字格式
+func ParseNumberLocale(locale string) (NumberFormat, error) {
+	if locale == "" {
+		return NumberFormat{Decimal: "."}, nil
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return NumberFormat{}, fmt.Errorf("unsupported locale: %s", locale)
+	}
+	nf, ok := numberFormats[strings.ToLower(parts[0])]
+	if !ok {
+		return NumberFormat{}, fmt.Errorf("unsupported locale: %s", locale)
+	}
+	return nf, nil
+}
+
+// Format 保留 precision 位小数并按本地格式输出
+func (nf NumberFormat) Format(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	if nf.Group != "" && len(intPart) > 3 {
+		var b strings.Builder
+		head := len(intPart) % 3
+		if head > 0 {
+			b.WriteString(intPart[:head])
+		}
+		for i := head; i < len(intPart); i += 3 {
+			if b.Len() > 0 {
+				b.WriteString(nf.Group)
+			}
+			b.WriteString(intPart[i : i+3])
+		}
+		intPart = b.String()
+	}
+	if frac == "" {
+		return sign + intPart
+	}
+	return sign + intPart + nf.Decimal + frac
+}
+
+// ExportOptions 导出参数
+type ExportOptions struct {
+	Format    string
+	Layout    string
+	Locale    string
+	Precision int
+	number    NumberFormat
+}
+
+// Validate 校验参数，并填充默认值
+func (o *ExportOptions) Validate() error {
+	if o.Format == "" {
+		o.Format = ExportFormatCSV
+	}
+	if o.Format != ExportFormatCSV && o.Format != ExportFormatXLSX && o.Format != ExportFormatJSON {
+		return fmt.Errorf("format must be csv, xlsx or json")
+	}
+	if o.Layout == "" {
+		o.Layout = ExportLayoutWide
+	}
+	if o.Layout != ExportLayoutWide && o.Layout != ExportLayoutLong {
+		return fmt.Errorf("layout must be wide or long")
+	}
+	if o.Precision < 0 || o.Precision > RatePrecision {
+		return fmt.Errorf("precision must be between 0 and %d", RatePrecision)
+	}
+	nf, err := ParseNumberLocale(o.Locale)
+	if err != nil {
+		return err
+	}
+	o.number = nf
+	return nil
+}
+
+// ContentType 响应的 Content-Type
+func (o *ExportOptions) ContentType() string {
+	switch o.Format {
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportFormatJSON:
+		return "application/json; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ParseExportPairs 解析逗号分隔的货币对列表：统一大写、去重并校验注册表
+func ParseExportPairs(raw string) ([]string, error) {
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		from, to, err := ParsePair(part)
+		if err != nil {
+			return nil, err
+		}
+		if from == to {
+			return nil, fmt.Errorf("invalid pair: %s", part)
+		}
+		for _, code := range []string{from, to} {
+			if !IsKnownCurrency(code) {
+				return nil, fmt.Errorf("unknown currency: %s", code)
+			}
+		}
+		pair := from + "/" + to
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, errors.New("no currency pairs given")
+	}
+	if len(pairs) > ExportMaxPairs {
+		return nil, fmt.Errorf("at most %d pairs allowed", ExportMaxPairs)
+	}
+	return pairs, nil
+}
+
+// StreamRateHistory 按日期升序逐日读取历史快照中的 USD 腿，计算各货币对的交叉汇率后回调 fn
+// rates 与 pairs 一一对应，某天缺腿的货币对为 nil；数据库结果逐行读取，不会一次性载入整个区间
+func StreamRateHistory(ctx context.Context, pairs []string, start, end time.Time, fn func(day time.Time, rates []*float64) error) error {
+	legs := make([][2]string, len(pairs))
+	var codes []string
+	seen := map[string]bool{"USD": true}
+	for i, pair := range pairs {
+		from, to, err := ParsePair(pair)
+		if err != nil {
+			return err
+		}
+		legs[i] = [2]string{from, to}
+		for _, code := range legs[i] {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	rows, err := global.Db.WithContext(ctx).
+		Model(&models.ExchangeRate{}).
+		Select("date, to_currency, rate").
+		Where("from_currency = ? AND to_currency IN ? AND date BETWEEN ? AND ?", "USD", codes, SnapshotDate(start), SnapshotDate(end)).
+		Order("date ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current time.Time
+	usdRates := map[string]float64{"USD": 1}
+	emit := func() error {
+		rates := make([]*float64, len(legs))
+		found := false
+		for i, leg := range legs {
+			if rate, err := CrossRate(usdRates[leg[0]], usdRates[leg[1]]); err == nil {
+				rates[i] = &rate
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+		return fn(current, rates)
+	}
+
+	for rows.Next() {
+		var date time.Time
+		var code string
+		var rate float64
+		if err := rows.Scan(&date, &code, &rate); err != nil {
+			return err
+		}
+		if day := SnapshotDate(date); !day.Equal(current) {
+			if !current.IsZero() {
+				if err := emit(); err != nil {
+					return err
+				}
+			}
+			current = day
+			usdRates = map[string]float64{"USD": 1}
+		}
+		usdRates[code] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current.IsZero() {
+		return nil
+	}
+	return emit()
+}
+
+// RateExporter 将逐日的汇率写成某种文件格式
+type RateExporter interface {
+	WriteDay(day time.Time, rates []*float64) error
+	// Flush 将已写入的内容推送到底层 Writer
+	Flush() error
+	// Close 写入文件结尾，不关闭底层 Writer
+	Close() error
+}
+
+// NewRateExporter 按 opts 创建导出器，opts 需已通过 Validate
+func NewRateExporter(w io.Writer, opts ExportOptions, pairs []string) (RateExporter, error) {
+	switch opts.Format {
+	case ExportFormatCSV:
+		return newCSVExporter(w, opts, pairs)
+	case ExportFormatXLSX:
+		return newXLSXExporter(w, opts, pairs)
+	case ExportFormatJSON:
+		return newJSONExporter(w, opts, pairs)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", opts.Format)
+}
+
+// exportHeader 表头：wide 为 date + 各货币对，long 为 date, pair, rate
+func exportHeader(layout string, pairs []string) []string {
+	if layout == ExportLayoutLong {
+		return []string{"date", "pair", "rate"}
+	}
+	return append([]string{"date"}, pairs...)
+}
+
+type csvExporter struct {
+	w     *csv.Writer
+	opts  ExportOptions
+	pairs []string
+}
+
+func newCSVExporter(w io.Writer, opts ExportOptions, pairs []string) (*csvExporter, error) {
+	cw := csv.NewWriter(w)
+	// 小数点为逗号时改用分号分隔，与这些地区的 Excel 默认行为一致
+	if opts.number.Decimal == "," {
+		cw.Comma = ';'
+	}
+	e := &csvExporter{w: cw, opts: opts, pairs: pairs}
+	return e, cw.Write(exportHeader(opts.Layout, pairs))
+}
+
+func (e *csvExporter) WriteDay(day time.Time, rates []*float64) error {
+	date := day.Format("2006-01-02")
+	if e.opts.Layout == ExportLayoutLong {
+		for i, rate := range rates {
+			if rate == nil {
+				continue
+			}
+			if err := e.w.Write([]string{date, e.pairs[i], e.opts.number.Format(*rate, e.opts.Precision)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	record := make([]string, 0, len(rates)+1)
+	record = append(record, date)
+	for _, rate := range rates {
+		if rate == nil {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, e.opts.number.Format(*rate, e.opts.Precision))
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+// jsonExporter 逐行写出 JSON 数组元素；数值保持为 JSON 数字 (按 precision 舍入)，不做本地化
+type jsonExporter struct {
+	w     io.Writer
+	opts  ExportOptions
+	pairs []string
+	rows  int
+}
+
+func newJSONExporter(w io.Writer, opts ExportOptions, pairs []string) (*jsonExporter, error) {
+	head, err := json.Marshal(map[string]interface{}{"layout": opts.Layout, "pairs": pairs})
+	if err != nil {
+		return nil, err
+	}
+	// 去掉结尾的 "}"，后面接着写 data 数组
+	_, err = fmt.Fprintf(w, `%s,"data":[`, head[:len(head)-1])
+	return &jsonExporter{w: w, opts: opts, pairs: pairs}, err
+}
+
+func (e *jsonExporter) round(v float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', e.opts.Precision, 64), 64)
+	return rounded
+}
+
+func (e *jsonExporter) writeRow(row interface{}) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if e.rows > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExporter) WriteDay(day time.Time, rates []*float64) error {
+	date := day.Format("2006-01-02")
+	if e.opts.Layout == ExportLayoutLong {
+		for i, rate := range rates {
+			if rate == nil {
+				continue
+			}
+			row := struct {
+				Date string  `json:"date"`
+				Pair string  `json:"pair"`
+				Rate float64 `json:"rate"`
+			}{date, e.pairs[i], e.round(*rate)}
+			if err := e.writeRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	values := make([]*float64, len(rates))
+	for i, rate := range rates {
+		if rate != nil {
+			v := e.round(*rate)
+			values[i] = &v
+		}
+	}
+	return e.writeRow(struct {
+		Date  string     `json:"date"`
+		Rates []*float64 `json:"rates"` // 与 pairs 一一对应，缺数据为 null
+	}{date, values})
+}
+
+func (e *jsonExporter) Flush() error {
+	return nil
+}
+
+func (e *jsonExporter) Close() error {
+	_, err := io.WriteString(e.w, "]}")
+	return err
+}
+
+// xlsxExporter 数值以数字单元格写入，千分位与小数点由 Excel 按用户的区域设置显示
+type xlsxExporter struct {
+	w     *xlsxWriter
+	opts  ExportOptions
+	pairs []string
+}
+
+func newXLSXExporter(w io.Writer, opts ExportOptions, pairs []string) (*xlsxExporter, error) {
+	header := exportHeader(opts.Layout, pairs)
+	xw, err := newXLSXWriter(w, "Rates", len(header), opts.Precision)
+	if err != nil {
+		return nil, err
+	}
+	cells := make([]xlsxCell, len(header))
+	for i, h := range header {
+		cells[i] = xlsxCell{Text: h, Style: xlsxStyleHeader}
+	}
+	return &xlsxExporter{w: xw, opts: opts, pairs: pairs}, xw.WriteRow(cells)
+}
+
+func (e *xlsxExporter) WriteDay(day time.Time, rates []*float64) error {
+	date := xlsxCell{Date: day}
+	if e.opts.Layout == ExportLayoutLong {
+		for i, rate := range rates {
+			if rate == nil {
+				continue
+			}
+			if err := e.w.WriteRow([]xlsxCell{date, {Text: e.pairs[i]}, {Number: rate}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cells := make([]xlsxCell, 0, len(rates)+1)
+	cells = append(cells, date)
+	for _, rate := range rates {
+		cells = append(cells, xlsxCell{Number: rate})
+	}
+	return e.w.WriteRow(cells)
+}
+
+func (e *xlsxExporter) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxExporter) Close() error {
+	return e.w.Close()
+}
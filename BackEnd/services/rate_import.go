@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportSource = "import"       // 导入文件未指定 source 时使用的数据源名称
+	SeedSource   = "bundled-seed" // 内置快照 config/seed_rates.json 的数据源名称
+)
+
+// ParseSnapshotFile 按扩展名解析汇率快照文件，支持 .json 与 .csv 两种格式。
+//
+// JSON：单个快照对象或快照数组，date (YYYY-MM-DD) 与 updatedAt (RFC3339) 至少给出一个，
+// source 可选，base 为空时视为 USD：
+//
+//	[{"date": "2024-01-02", "base": "USD", "source": "ecb", "rates": {"CNY": 7.1, "EUR": 0.91}}]
+//
+// CSV：首行为表头，列顺序不限，source 列可选；同一 (date, base) 的行组成一份快照：
+//
+//	date,base,currency,rate,source
+//	2024-01-02,USD,CNY,7.1,ecb
+func ParseSnapshotFile(path string) ([]*RateSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshots []*RateSnapshot
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		snapshots, err = parseSnapshotJSON(f)
+	case ".csv":
+		snapshots, err = parseSnapshotCSV(f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported file type, expected .json or .csv", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshots, nil
+}
+
+type snapshotFileEntry struct {
+	Date      string             `json:"date"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Base      string             `json:"base"`
+	Source    string             `json:"source"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+func parseSnapshotJSON(r io.Reader) ([]*RateSnapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []snapshotFileEntry
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		var entry snapshotFileEntry
+		err = json.Unmarshal(data, &entry)
+		entries = append(entries, entry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("json decode failed: %w", err)
+	}
+
+	snapshots := make([]*RateSnapshot, 0, len(entries))
+	for i, e := range entries {
+		updatedAt := e.UpdatedAt
+		if e.Date != "" {
+			day, err := time.ParseInLocation("2006-01-02", e.Date, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: invalid date %q", i, e.Date)
+			}
+			if updatedAt.IsZero() {
+				updatedAt = day
+			}
+		}
+		if updatedAt.IsZero() {
+			return nil, fmt.Errorf("entry %d: date or updatedAt is required", i)
+		}
+		if len(e.Rates) == 0 {
+			return nil, fmt.Errorf("entry %d: rates is empty", i)
+		}
+		snapshots = append(snapshots, newImportedSnapshot(e.Base, e.Source, updatedAt, e.Rates))
+	}
+	return snapshots, nil
+}
+
+func parseSnapshotCSV(r io.Reader) ([]*RateSnapshot, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header failed: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "base", "currency", "rate"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	byKey := make(map[string]*RateSnapshot)
+	var keys []string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		day, err := time.ParseInLocation("2006-01-02", field(record, "date"), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, field(record, "date"))
+		}
+		rate, err := strconv.ParseFloat(field(record, "rate"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, field(record, "rate"))
+		}
+		currency := strings.ToUpper(field(record, "currency"))
+		if currency == "" {
+			return nil, fmt.Errorf("line %d: currency is required", line)
+		}
+
+		base := field(record, "base")
+		key := day.Format("2006-01-02") + "|" + strings.ToUpper(base)
+		snapshot, ok := byKey[key]
+		if !ok {
+			snapshot = newImportedSnapshot(base, field(record, "source"), day, map[string]float64{})
+			byKey[key] = snapshot
+			keys = append(keys, key)
+		}
+		snapshot.Rates[currency] = rate
+	}
+
+	snapshots := make([]*RateSnapshot, 0, len(keys))
+	for _, key := range keys {
+		snapshots = append(snapshots, byKey[key])
+	}
+	return snapshots, nil
+}
+
+func newImportedSnapshot(base, source string, updatedAt time.Time, rates map[string]float64) *RateSnapshot {
+	if base == "" {
+		base = "USD"
+	}
+	if source == "" {
+		source = ImportSource
+	}
+	return &RateSnapshot{Base: strings.ToUpper(base), Rates: rates, Provider: source, UpdatedAt: updatedAt}
+}
+
+// ImportResult 一次导入的结果
+type ImportResult struct {
+	Days      int       `json:"days"`      // 写入历史表的快照数
+	Latest    time.Time `json:"latest"`    // 最新一份快照的时间
+	Activated bool      `json:"activated"` // 最新快照是否成为当前生效的 Redis 快照
+}
+
+// ImportSnapshots 将快照写入历史表；最新一份比当前生效的快照更新 (或当前没有快照) 时同时写入 Redis 基准 Hash
+// 与定时刷新共用刷新锁，避免与正在运行的服务交错写入
+func ImportSnapshots(ctx context.Context, snapshots []*RateSnapshot) (*ImportResult, error) {
+	var result *ImportResult
+	err := WithLeaseLock(ctx, RateRefreshLock, RateRefreshLeaseTTL, func(ctx context.Context) error {
+		var err error
+		result, err = importSnapshots(ctx, snapshots)
+		return err
+	})
+	if errors.Is(err, ErrLockHeld) {
+		return nil, ErrRefreshInProgress
+	}
+	return result, err
+}
+
+func importSnapshots(ctx context.Context, snapshots []*RateSnapshot) (*ImportResult, error) {
+	if len(snapshots) == 0 {
+		return nil, errors.New("no snapshots to import")
+	}
+	// 写入任何数据之前校验全部快照，任何一天有问题都放弃整个导入
+	for _, s := range snapshots {
+		if err := checkImportedSnapshot(s); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.UpdatedAt.Format("2006-01-02"), err)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].UpdatedAt.Before(snapshots[j].UpdatedAt) })
+
+	result := &ImportResult{}
+	for _, s := range snapshots {
+		// 抓取时间记为快照自身的时间，导入的旧数据在响应中会如实标记为 stale
+		if err := saveSnapshotHistory(global.Db, s, SnapshotDate(s.UpdatedAt), s.UpdatedAt); err != nil {
+			return result, fmt.Errorf("%s: %w", s.UpdatedAt.Format("2006-01-02"), err)
+		}
+		result.Days++
+	}
+
+	latest := snapshots[len(snapshots)-1]
+	result.Latest = latest.UpdatedAt
+
+	current, err := LoadBaseSnapshot(ctx)
+	if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+		return result, err
+	}
+	if current == nil || current.FetchedAt.Before(latest.UpdatedAt) {
+		if err := publishSnapshot(ctx, latest, current, latest.UpdatedAt); err != nil {
+			return result, err
+		}
+		result.Activated = true
+	} else {
+		// 只补录了历史，publishSnapshot 不会执行，需单独让派生数据缓存失效
+		refreshDerivedRateCaches(ctx)
+	}
+	return result, nil
+}
+
+// checkImportedSnapshot 导入的快照只能包含注册表中的货币和有限正数汇率，并换算为 USD 基准
+// 零或非法的汇率写入后会让所有相关交叉汇率的计算出错
+func checkImportedSnapshot(s *RateSnapshot) error {
+	if !IsKnownCurrency(s.Base) {
+		return fmt.Errorf("unknown base currency %s", s.Base)
+	}
+	codes := make([]string, 0, len(s.Rates))
+	for code := range s.Rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !IsKnownCurrency(code) {
+			return fmt.Errorf("unknown currency %s", code)
+		}
+	}
+	if invalid := invalidRates(s.Rates); len(invalid) > 0 {
+		return fmt.Errorf("invalid rates: %s", strings.Join(invalid, ","))
+	}
+	if err := s.ToUSDBase(); err != nil {
+		return err
+	}
+	// 换算时极小的基准汇率仍可能溢出
+	if invalid := invalidRates(s.Rates); len(invalid) > 0 {
+		return fmt.Errorf("invalid rates after rebasing to USD: %s", strings.Join(invalid, ","))
+	}
+	return nil
+}
+
+// ImportSnapshotFiles 解析并导入多个快照文件
+func ImportSnapshotFiles(ctx context.Context, paths ...string) (*ImportResult, error) {
+	var snapshots []*RateSnapshot
+	for _, path := range paths {
+		parsed, err := ParseSnapshotFile(path)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, parsed...)
+	}
+	return ImportSnapshots(ctx, snapshots)
+}
+
+// seedIfEmpty 启动时实时抓取失败且没有任何可用快照时，从 exchangeRate.seedFile 导入内置快照
+// 调用方已持有刷新锁
+func seedIfEmpty(ctx context.Context) {
+	path := config.AppConfig.ExchangeRate.SeedFile
+	if path == "" {
+		return
+	}
+	if _, err := LoadBaseSnapshot(ctx); !errors.Is(err, ErrRatesUnavailable) {
+		return
+	}
+
+	snapshots, err := ParseSnapshotFile(path)
+	if err == nil {
+		var result *ImportResult
+		if result, err = importSnapshots(ctx, snapshots); err == nil {
+			log.Printf("Seeded exchange rates from %s (%d snapshots, latest %s)\n", path, result.Days, result.Latest.Format("2006-01-02"))
+			return
+		}
+	}
+	log.Printf("Seeding exchange rates from %s failed: %v\n", path, err)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckImportedSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{"valid", "date,base,currency,rate\n2024-01-02,EUR,USD,1.1\n2024-01-02,EUR,CNY,7.8\n", ""},
+		{"zero rate", "date,base,currency,rate\n2024-01-02,USD,CNY,0\n", "CNY"},
+		{"negative rate", "date,base,currency,rate\n2024-01-02,USD,JPY,-1\n", "JPY"},
+		{"NaN rate", "date,base,currency,rate\n2024-01-02,USD,EUR,NaN\n", "EUR"},
+		{"Inf rate", "date,base,currency,rate\n2024-01-02,USD,GBP,+Inf\n", "GBP"},
+		{"unknown currency", "date,base,currency,rate\n2024-01-02,USD,XYZ,1.5\n", "XYZ"},
+		{"unknown base", "date,base,currency,rate\n2024-01-02,QQQ,USD,1.5\n", "QQQ"},
+		{"zero base leg", "date,base,currency,rate\n2024-01-02,EUR,USD,0\n2024-01-02,EUR,CNY,7.8\n", "USD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots, err := parseSnapshotCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			err = checkImportedSnapshot(snapshots[0])
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if snapshots[0].Base != "USD" {
+					t.Errorf("base = %s, want USD", snapshots[0].Base)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeedFileIsValid(t *testing.T) {
+	snapshots, err := ParseSnapshotFile("../config/seed_rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range snapshots {
+		if err := checkImportedSnapshot(s); err != nil {
+			t.Errorf("seed snapshot %s: %v", s.UpdatedAt.Format("2006-01-02"), err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatrixMaxCodes 矩阵最多包含的货币数量
+const MatrixMaxCodes = 50
+
+// RateMatrix N×N 交叉汇率表
+// direct 视图: Rates[i][j] = 1 Codes[i] 可兑换多少 Codes[j]
+// inverse 视图: Rates[i][j] = 1 Codes[j] 可兑换多少 Codes[i]
+// 快照中缺失的货币所在行列为 null
+type RateMatrix struct {
+	Codes   []string     `json:"codes"`
+	View    string       `json:"view"`
+	Rates   [][]*float64 `json:"rates"`
+	Missing []string     `json:"missing,omitempty"`
+	// 受人工汇率影响的货币对，格式 FROM/TO (按 direct 视图)
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// ParseCurrencyCodes 解析逗号分隔的货币代码列表：统一大写、去重并校验注册表
+func ParseCurrencyCodes(raw string, max int) ([]string, error) {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		code := strings.ToUpper(strings.TrimSpace(part))
+		if code == "" || seen[code] {
+			continue
+		}
+		if !IsKnownCurrency(code) {
+			return nil, fmt.Errorf("unknown currency: %s", code)
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no currency codes given")
+	}
+	if max > 0 && len(codes) > max {
+		return nil, fmt.Errorf("at most %d currencies allowed", max)
+	}
+	return codes, nil
+}
+
+// BuildRateMatrix 基于 USD 基准快照计算交叉汇率矩阵，生效的人工汇率优先
+func BuildRateMatrix(snapshot *BaseSnapshot, overrides *OverrideSet, codes []string, inverse bool) *RateMatrix {
+	m := &RateMatrix{Codes: codes, View: "direct", Rates: make([][]*float64, len(codes))}
+	if inverse {
+		m.View = "inverse"
+	}
+
+	available := make([]bool, len(codes))
+	for i, code := range codes {
+		if _, available[i] = overrides.USDRate(code); !available[i] {
+			_, available[i] = snapshot.USDRate(code)
+		}
+		if !available[i] {
+			m.Missing = append(m.Missing, code)
+		}
+	}
+
+	for i := range codes {
+		row := make([]*float64, len(codes))
+		for j := range codes {
+			if i == j && available[i] {
+				one := 1.0
+				row[j] = &one
+				continue
+			}
+			// Rate(i -> j) = Rate(USD -> j) / Rate(USD -> i)
+			usdToFrom, usdToTo, overridden, ok := overrides.Legs(snapshot, codes[i], codes[j])
+			if !ok {
+				continue
+			}
+			rate := usdToTo / usdToFrom
+			if inverse {
+				rate = usdToFrom / usdToTo
+			}
+			row[j] = &rate
+			if overridden {
+				m.Overridden = append(m.Overridden, codes[i]+"/"+codes[j])
+			}
+		}
+		m.Rates[i] = row
+	}
+	return m
+}
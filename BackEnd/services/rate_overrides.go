@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OverrideCacheKey    = "rates:overrides:active"
+	OverrideCacheExpire = time.Minute // 多实例写入与读取交错时，最多在该时间内读到旧配置
+)
+
+var ErrOverrideInactive = errors.New("rate override is not active")
+
+// OverrideSet 某一时刻生效的人工汇率
+type OverrideSet struct {
+	legs  map[string]*models.RateOverride // code -> 覆盖 USD->code 的记录
+	pairs map[string]*models.RateOverride // "FROM/TO" -> 非 USD 货币对的记录
+}
+
+// LoadActiveOverrides 读取 now 时刻生效 (未撤销且未过期) 的人工汇率
+func LoadActiveOverrides(now time.Time) (*OverrideSet, error) {
+	list, err := loadActiveOverrideList()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &OverrideSet{legs: make(map[string]*models.RateOverride), pairs: make(map[string]*models.RateOverride)}
+	for i := range list {
+		o := &list[i]
+		switch {
+		case !o.ExpiresAt.After(now):
+			continue
+		case o.FromCurrency == "USD":
+			set.legs[o.ToCurrency] = o
+		case o.ToCurrency == "USD":
+			set.legs[o.FromCurrency] = o
+		default:
+			set.pairs[o.FromCurrency+"/"+o.ToCurrency] = o
+		}
+	}
+	return set, nil
+}
+
+// loadActiveOverrideList 读取未撤销的人工汇率，结果缓存在 Redis 中，创建或撤销时失效
+// 过期时间在 LoadActiveOverrides 中按 now 判断，缓存期间到期的记录不会继续生效
+func loadActiveOverrideList() ([]models.RateOverride, error) {
+	ctx := context.Background()
+	if cached, err := global.RedisDB.Get(ctx, OverrideCacheKey).Result(); err == nil {
+		var list []models.RateOverride
+		if json.Unmarshal([]byte(cached), &list) == nil {
+			return list, nil
+		}
+	}
+
+	var list []models.RateOverride
+	if err := global.Db.Where("active = ? AND expires_at > ?", true, time.Now()).Order("created_at").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(list); err == nil {
+		global.RedisDB.Set(ctx, OverrideCacheKey, data, OverrideCacheExpire)
+	}
+	return list, nil
+}
+
+// clearOverrideCache 人工汇率变更后清除缓存
+func clearOverrideCache() {
+	global.RedisDB.Del(context.Background(), OverrideCacheKey)
+}
+
+// Empty 没有生效的人工汇率
+func (s *OverrideSet) Empty() bool {
+	return s == nil || len(s.legs)+len(s.pairs) == 0
+}
+
+// USDRate 返回人工钉住的 USD -> code 汇率
+func (s *OverrideSet) USDRate(code string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	o, ok := s.legs[code]
+	if !ok {
+		return 0, false
+	}
+	if o.FromCurrency == "USD" {
+		return o.Rate, true
+	}
+	return 1 / o.Rate, true
+}
+
+// Legs 返回 from、to 两条 USD 基准腿，供 CrossRate / ConvertAmount 使用
+// 货币对被人工钉住时返回 (1, rate) 或其反向；overridden 表示结果受到人工汇率影响
+func (s *OverrideSet) Legs(snapshot *BaseSnapshot, from, to string) (usdToFrom, usdToTo float64, overridden, ok bool) {
+	if s != nil {
+		if o, found := s.pairs[from+"/"+to]; found {
+			return 1, o.Rate, true, true
+		}
+		if o, found := s.pairs[to+"/"+from]; found {
+			return o.Rate, 1, true, true
+		}
+	}
+
+	usdToFrom, fromOverridden := s.USDRate(from)
+	if !fromOverridden {
+		if usdToFrom, ok = snapshot.USDRate(from); !ok {
+			return 0, 0, false, false
+		}
+	}
+	usdToTo, toOverridden := s.USDRate(to)
+	if !toOverridden {
+		if usdToTo, ok = snapshot.USDRate(to); !ok {
+			return 0, 0, false, false
+		}
+	}
+	return usdToFrom, usdToTo, fromOverridden || toOverridden, true
+}
+
+// CreateRateOverride 创建人工汇率，同一货币对 (含反向) 已有生效记录时将其替换，全部操作写入审计
+func CreateRateOverride(o *models.RateOverride) error {
+	o.Active = true
+	defer clearOverrideCache()
+	return global.Db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.RateOverride
+		if err := tx.Where("active = ? AND ((from_currency = ? AND to_currency = ?) OR (from_currency = ? AND to_currency = ?))",
+			true, o.FromCurrency, o.ToCurrency, o.ToCurrency, o.FromCurrency).Find(&existing).Error; err != nil {
+			return err
+		}
+		for i := range existing {
+			if err := deactivateOverride(tx, &existing[i], models.OverrideActionReplace, o.Author); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(o).Error; err != nil {
+			return err
+		}
+		return auditOverride(tx, o, models.OverrideActionCreate, o.Author)
+	})
+}
+
+// RevokeRateOverride 撤销人工汇率
+func RevokeRateOverride(id uint, actor string) (*models.RateOverride, error) {
+	var o models.RateOverride
+	err := global.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&o, id).Error; err != nil {
+			return err
+		}
+		if !o.Active {
+			return ErrOverrideInactive
+		}
+		return deactivateOverride(tx, &o, models.OverrideActionRevoke, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	clearOverrideCache()
+	return &o, nil
+}
+
+func deactivateOverride(tx *gorm.DB, o *models.RateOverride, action, actor string) error {
+	now := time.Now()
+	o.Active = false
+	o.RevokedBy = actor
+	o.RevokedAt = &now
+	if err := tx.Save(o).Error; err != nil {
+		return err
+	}
+	return auditOverride(tx, o, action, actor)
+}
+
+func auditOverride(tx *gorm.DB, o *models.RateOverride, action, actor string) error {
+	detail, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.RateOverrideAudit{
+		OverrideID: o.ID,
+		Action:     action,
+		Actor:      actor,
+		Detail:     string(detail),
+	}).Error
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchangeapp/config"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ExchangeBaseURL = "https://v6.exchangerate-api.com/v6/%s/latest/USD"
+
+// RateSnapshot 数据源返回的一份汇率快照
+type RateSnapshot struct {
+	Base      string             // 基准货币
+	Rates     map[string]float64 // Base -> Currency
+	Provider  string             // 提供本次数据的数据源名称
+	UpdatedAt time.Time          // 数据源声明的更新时间，未知时为零值
+	Partial   bool               // 数据源只发布部分货币 (如 ECB 约 30 种)，生效前用上一份快照补齐其余货币
+}
+
+// RateProvider 汇率数据源
+type RateProvider interface {
+	Name() string
+	FetchLatest(ctx context.Context) (*RateSnapshot, error)
+}
+
+// HistoricalRateProvider 可以一次性返回全部历史快照的数据源，用于回填历史表
+type HistoricalRateProvider interface {
+	RateProvider
+	FetchHistory(ctx context.Context) ([]*RateSnapshot, error)
+}
+
+// ToUSDBase 将快照换算为 USD 基准，updateRates 与 GetLatestRate 都假定基准为 USD
+func (s *RateSnapshot) ToUSDBase() error {
+	base := strings.ToUpper(s.Base)
+	if base == "" || base == "USD" {
+		s.Base = "USD"
+		return nil
+	}
+
+	// Rate(USD -> X) = Rate(Base -> X) / Rate(Base -> USD)
+	baseToUSD, ok := s.Rates["USD"]
+	if !ok || baseToUSD <= 0 {
+		return fmt.Errorf("cannot rebase %s snapshot to USD: missing USD rate", base)
+	}
+
+	rates := make(map[string]float64, len(s.Rates)+1)
+	for code, rate := range s.Rates {
+		rates[code] = rate / baseToUSD
+	}
+	rates[base] = 1 / baseToUSD
+	rates["USD"] = 1
+
+	s.Base = "USD"
+	s.Rates = rates
+	return nil
+}
+
+// FillMissing 用上一份快照补齐本快照未发布的货币，返回补齐的货币数
+// 补齐的汇率与上一份相同，不会触发跳变校验，覆盖率校验也因此只针对数据源实际发布的货币
+func (s *RateSnapshot) FillMissing(previous *BaseSnapshot) int {
+	if previous == nil {
+		return 0
+	}
+	filled := 0
+	for code, rate := range previous.Rates {
+		if _, ok := s.Rates[code]; !ok {
+			s.Rates[code] = rate
+			filled++
+		}
+	}
+	return filled
+}
+
+// NewRateProvider 根据配置创建数据源，timeout 为单次请求超时
+func NewRateProvider(cfg config.RateProviderConfig, timeout time.Duration) (RateProvider, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch cfg.Type {
+	case "exchangerate-api":
+		apiKey := os.ExpandEnv(cfg.APIKey)
+		if apiKey == "" {
+			return nil, fmt.Errorf("provider %s: apiKey is required", name)
+		}
+		url := cfg.URL
+		if url == "" {
+			url = ExchangeBaseURL
+		}
+		return &ExchangeRateAPIProvider{name: name, apiKey: apiKey, urlFormat: url, client: client}, nil
+	case "file":
+		if cfg.File == "" {
+			return nil, fmt.Errorf("provider %s: file is required", name)
+		}
+		return &FileRateProvider{name: name, path: cfg.File}, nil
+	case "http-json":
+		if cfg.URL == "" || cfg.RatesPath == "" {
+			return nil, fmt.Errorf("provider %s: url and ratesPath are required", name)
+		}
+		return &HTTPJSONProvider{
+			name:          name,
+			url:           cfg.URL,
+			base:          cfg.Base,
+			basePath:      cfg.BasePath,
+			ratesPath:     cfg.RatesPath,
+			timestampPath: cfg.TimestampPath,
+			client:        client,
+		}, nil
+	case "ecb":
+		url, historyURL := cfg.URL, cfg.HistoryURL
+		if url == "" {
+			url = ECBDailyURL
+		}
+		if historyURL == "" {
+			historyURL = ECBHistoryURL
+		}
+		// 历史文件较大，单独放宽超时
+		return &ECBProvider{name: name, url: url, historyURL: historyURL, client: client, historyClient: &http.Client{Timeout: 2 * time.Minute}}, nil
+	default:
+		return nil, fmt.Errorf("provider %s: unknown type %q", name, cfg.Type)
+	}
+}
+
+// NewRateProviders 按配置顺序创建数据源，配置错误的数据源会被跳过
+func NewRateProviders(cfgs []config.RateProviderConfig, timeout time.Duration) ([]RateProvider, []error) {
+	var providers []RateProvider
+	var errs []error
+	for _, cfg := range cfgs {
+		p, err := NewRateProvider(cfg, timeout)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers, errs
+}
+
+// FetchFromProviders 依次尝试数据源，返回第一个成功且被 accept 接受的 USD 基准快照
+// accept 为空时不做额外检查；accept 返回错误 (如校验不通过被隔离) 时继续尝试下一个数据源
+func FetchFromProviders(ctx context.Context, providers []RateProvider, accept func(*RateSnapshot) error) (*RateSnapshot, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no rate providers configured")
+	}
+
+	var errs []error
+	for _, p := range providers {
+		snapshot, err := p.FetchLatest(ctx)
+		if err == nil {
+			err = snapshot.ToUSDBase()
+		}
+		if err == nil && len(snapshot.Rates) == 0 {
+			err = errors.New("empty rates")
+		}
+		if err == nil {
+			snapshot.Provider = p.Name()
+			if accept != nil {
+				err = accept(snapshot)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		return snapshot, nil
+	}
+	return nil, fmt.Errorf("all rate providers failed: %w", errors.Join(errs...))
+}
+
+// ======================= exchangerate-api.com v6 =========================
+
+// ExchangeRateAPIProvider v6.exchangerate-api.com
+type ExchangeRateAPIProvider struct {
+	name      string
+	apiKey    string
+	urlFormat string // 含一个 %s 占位符用于 API Key
+	client    *http.Client
+}
+
+func (p *ExchangeRateAPIProvider) Name() string { return p.name }
+
+func (p *ExchangeRateAPIProvider) FetchLatest(ctx context.Context) (*RateSnapshot, error) {
+	url := p.urlFormat
+	if strings.Contains(url, "%s") {
+		url = fmt.Sprintf(url, p.apiKey)
+	}
+
+	var result struct {
+		Result             string             `json:"result"`
+		ErrorType          string             `json:"error-type"`
+		BaseCode           string             `json:"base_code"`
+		TimeLastUpdateUnix int64              `json:"time_last_update_unix"`
+		ConversionRates    map[string]float64 `json:"conversion_rates"`
+	}
+	if err := getJSON(ctx, p.client, url, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Result != "success" {
+		return nil, fmt.Errorf("API error: %s %s", result.Result, result.ErrorType)
+	}
+
+	snapshot := &RateSnapshot{Base: result.BaseCode, Rates: result.ConversionRates}
+	if result.TimeLastUpdateUnix > 0 {
+		snapshot.UpdatedAt = time.Unix(result.TimeLastUpdateUnix, 0)
+	}
+	return snapshot, nil
+}
+
+// ======================= 本地静态文件 =========================
+
+// FileRateProvider 从本地 JSON 文件读取汇率，格式:
+//
+//	{"base": "USD", "updatedAt": "2024-01-02T00:00:00Z", "rates": {"CNY": 7.1, "EUR": 0.92}}
+type FileRateProvider struct {
+	name string
+	path string
+}
+
+func (p *FileRateProvider) Name() string { return p.name }
+
+func (p *FileRateProvider) FetchLatest(ctx context.Context) (*RateSnapshot, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Base      string             `json:"base"`
+		UpdatedAt time.Time          `json:"updatedAt"`
+		Rates     map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("json decode failed: %w", err)
+	}
+
+	return &RateSnapshot{Base: file.Base, Rates: file.Rates, UpdatedAt: file.UpdatedAt}, nil
+}
+
+// ======================= 通用 HTTP JSON =========================
+
+// HTTPJSONProvider 通用 HTTP JSON 数据源，字段位置通过点号路径配置，例如 "data.rates"
+type HTTPJSONProvider struct {
+	name          string
+	url           string
+	base          string
+	basePath      string
+	ratesPath     string
+	timestampPath string
+	client        *http.Client
+}
+
+func (p *HTTPJSONProvider) Name() string { return p.name }
+
+func (p *HTTPJSONProvider) FetchLatest(ctx context.Context) (*RateSnapshot, error) {
+	var body map[string]interface{}
+	if err := getJSON(ctx, p.client, p.url, &body); err != nil {
+		return nil, err
+	}
+
+	rawRates, ok := lookupPath(body, p.ratesPath).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rates not found at %q", p.ratesPath)
+	}
+	rates := make(map[string]float64, len(rawRates))
+	for code, v := range rawRates {
+		rate, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid rate for %s: %v", code, v)
+		}
+		rates[strings.ToUpper(code)] = rate
+	}
+
+	snapshot := &RateSnapshot{Base: p.base, Rates: rates}
+	if p.basePath != "" {
+		base, ok := lookupPath(body, p.basePath).(string)
+		if !ok {
+			return nil, fmt.Errorf("base currency not found at %q", p.basePath)
+		}
+		snapshot.Base = base
+	}
+	if p.timestampPath != "" {
+		snapshot.UpdatedAt = parseTimestamp(lookupPath(body, p.timestampPath))
+	}
+	return snapshot, nil
+}
+
+// ======================= 工具函数 =========================
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("json decode failed: %w", err)
+	}
+	return nil
+}
+
+// lookupPath 按点号路径在 JSON 对象中取值，路径为空时返回对象本身
+func lookupPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseTimestamp 支持 Unix 秒、RFC3339 以及 YYYY-MM-DD 三种格式
+func parseTimestamp(v interface{}) time.Time {
+	switch t := v.(type) {
+	case float64:
+		return time.Unix(int64(t), 0)
+	case string:
+		if sec, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+		if ts, err := time.Parse(time.RFC3339, t); err == nil {
+			return ts
+		}
+		if ts, err := time.ParseInLocation("2006-01-02", t, time.Local); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
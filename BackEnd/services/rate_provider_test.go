@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"exchangeapp/config"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestProvider(t *testing.T, cfg config.RateProviderConfig) RateProvider {
+	t.Helper()
+	p, err := NewRateProvider(cfg, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewRateProvider(%s): %v", cfg.Name, err)
+	}
+	return p
+}
+
+func jsonServer(t *testing.T, status int, body string, hits *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			atomic.AddInt32(hits, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchFromProvidersFailover(t *testing.T) {
+	var firstHits, secondHits, thirdHits int32
+	down := jsonServer(t, http.StatusInternalServerError, `{}`, &firstHits)
+	ok := jsonServer(t, http.StatusOK, `{"base":"USD","rates":{"CNY":7.1,"EUR":0.9}}`, &secondHits)
+	unused := jsonServer(t, http.StatusOK, `{"base":"USD","rates":{"CNY":1}}`, &thirdHits)
+
+	providers := []RateProvider{
+		newTestProvider(t, config.RateProviderConfig{Name: "primary", Type: "http-json", URL: down.URL, BasePath: "base", RatesPath: "rates"}),
+		newTestProvider(t, config.RateProviderConfig{Name: "secondary", Type: "http-json", URL: ok.URL, BasePath: "base", RatesPath: "rates"}),
+		newTestProvider(t, config.RateProviderConfig{Name: "tertiary", Type: "http-json", URL: unused.URL, BasePath: "base", RatesPath: "rates"}),
+	}
+
+	snapshot, err := FetchFromProviders(context.Background(), providers, nil)
+	if err != nil {
+		t.Fatalf("FetchFromProviders: %v", err)
+	}
+	if snapshot.Provider != "secondary" {
+		t.Errorf("provider = %q, want secondary", snapshot.Provider)
+	}
+	if snapshot.Rates["CNY"] != 7.1 {
+		t.Errorf("CNY = %v, want 7.1", snapshot.Rates["CNY"])
+	}
+	if firstHits != 1 || secondHits != 1 || thirdHits != 0 {
+		t.Errorf("hits = %d/%d/%d, want 1/1/0", firstHits, secondHits, thirdHits)
+	}
+}
+
+func TestFetchFromProvidersAllFail(t *testing.T) {
+	down := jsonServer(t, http.StatusBadGateway, `{}`, nil)
+	empty := jsonServer(t, http.StatusOK, `{"rates":{}}`, nil)
+
+	providers := []RateProvider{
+		newTestProvider(t, config.RateProviderConfig{Name: "down", Type: "http-json", URL: down.URL, Base: "USD", RatesPath: "rates"}),
+		newTestProvider(t, config.RateProviderConfig{Name: "empty", Type: "http-json", URL: empty.URL, Base: "USD", RatesPath: "rates"}),
+	}
+
+	_, err := FetchFromProviders(context.Background(), providers, nil)
+	if err == nil {
+		t.Fatal("expected error when every provider fails")
+	}
+	for _, name := range []string{"down", "empty"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention provider %s", err, name)
+		}
+	}
+
+	if _, err := FetchFromProviders(context.Background(), nil, nil); err == nil {
+		t.Error("expected error with no providers")
+	}
+}
+
+func TestHTTPJSONProviderFieldPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		cfg     config.RateProviderConfig
+		want    map[string]float64 // 换算为 USD 基准后
+		wantAt  time.Time
+		wantErr bool
+	}{
+		{
+			name:   "nested paths and unix timestamp",
+			body:   `{"data":{"meta":{"base":"USD","ts":1704153600},"quotes":{"cny":7.1,"eur":"0.9"}}}`,
+			cfg:    config.RateProviderConfig{BasePath: "data.meta.base", RatesPath: "data.quotes", TimestampPath: "data.meta.ts"},
+			want:   map[string]float64{"CNY": 7.1, "EUR": 0.9},
+			wantAt: time.Unix(1704153600, 0),
+		},
+		{
+			name:   "fixed base rebased to USD with RFC3339 timestamp",
+			body:   `{"result":{"rates":{"USD":1.25,"CNY":8.75}},"updated":"2024-01-02T15:04:05Z"}`,
+			cfg:    config.RateProviderConfig{Base: "EUR", RatesPath: "result.rates", TimestampPath: "updated"},
+			want:   map[string]float64{"USD": 1, "CNY": 7, "EUR": 0.8},
+			wantAt: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:    "missing rates path",
+			body:    `{"data":{}}`,
+			cfg:     config.RateProviderConfig{Base: "USD", RatesPath: "data.rates"},
+			wantErr: true,
+		},
+		{
+			name:    "missing base path",
+			body:    `{"rates":{"CNY":7.1}}`,
+			cfg:     config.RateProviderConfig{BasePath: "meta.base", RatesPath: "rates"},
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric rate",
+			body:    `{"rates":{"CNY":"n/a"}}`,
+			cfg:     config.RateProviderConfig{Base: "USD", RatesPath: "rates"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := jsonServer(t, http.StatusOK, tt.body, nil)
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.URL = "fake", "http-json", srv.URL
+
+			snapshot, err := FetchFromProviders(context.Background(), []RateProvider{newTestProvider(t, cfg)}, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", snapshot)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchFromProviders: %v", err)
+			}
+			for code, want := range tt.want {
+				if got := snapshot.Rates[code]; math.Abs(got-want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", code, got, want)
+				}
+			}
+			if !snapshot.UpdatedAt.Equal(tt.wantAt) {
+				t.Errorf("UpdatedAt = %v, want %v", snapshot.UpdatedAt, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestNewRateProviderValidation(t *testing.T) {
+	tests := []config.RateProviderConfig{
+		{Name: "no-key", Type: "exchangerate-api"},
+		{Name: "no-file", Type: "file"},
+		{Name: "no-path", Type: "http-json", URL: "http://localhost"},
+		{Name: "unknown", Type: "carrier-pigeon"},
+	}
+	for _, cfg := range tests {
+		if _, err := NewRateProvider(cfg, time.Second); err == nil {
+			t.Errorf("%s: expected configuration error", cfg.Name)
+		}
+	}
+}
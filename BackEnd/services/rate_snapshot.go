@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var ErrRatesUnavailable = errors.New("exchange rates unavailable")
+
+// BaseSnapshot 当前生效的 USD 基准汇率快照
+type BaseSnapshot struct {
+	Rates     map[string]float64 // USD -> Currency
+	Source    string
+	Date      time.Time // 快照所属的日期 (历史表中的 date)
+	FetchedAt time.Time // 本服务抓取的时间
+	UpdatedAt time.Time // 数据源声明的更新时间，未知时为零值
+}
+
+// AsOf 数据实际对应的时间：优先使用数据源声明的更新时间，否则为抓取时间
+func (s *BaseSnapshot) AsOf() time.Time {
+	if !s.UpdatedAt.IsZero() {
+		return s.UpdatedAt
+	}
+	return s.FetchedAt
+}
+
+// Stale 快照抓取后超过 exchangeRate.staleAfter 仍未刷新；抓取时间未知时视为过期
+func (s *BaseSnapshot) Stale(now time.Time) bool {
+	if s.FetchedAt.IsZero() {
+		return true
+	}
+	return now.Sub(s.FetchedAt) > config.AppConfig.ExchangeRate.StaleAfter
+}
+
+// USDRate 返回 USD -> code 的汇率，USD 自身恒为 1
+func (s *BaseSnapshot) USDRate(code string) (float64, bool) {
+	if code == "USD" {
+		return 1, true
+	}
+	rate, ok := s.Rates[code]
+	return rate, ok && rate > 0
+}
+
+// LoadBaseSnapshot 读取当前快照：汇率和元信息在同一个 pipeline 中读取，Redis 无数据时降级查库
+func LoadBaseSnapshot(ctx context.Context) (*BaseSnapshot, error) {
+	pipe := global.RedisDB.Pipeline()
+	ratesCmd := pipe.HGetAll(ctx, ExchangeRateRedisKey)
+	metaCmd := pipe.HGetAll(ctx, ExchangeRateMetaRedisKey)
+	if _, err := pipe.Exec(ctx); err == nil && len(ratesCmd.Val()) > 0 {
+		snapshot := &BaseSnapshot{Rates: make(map[string]float64, len(ratesCmd.Val()))}
+		for code, valStr := range ratesCmd.Val() {
+			rate, err := strconv.ParseFloat(valStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cached rate for %s: %w", code, err)
+			}
+			snapshot.Rates[code] = rate
+		}
+		snapshot.applyMeta(metaCmd.Val())
+		return snapshot, nil
+	}
+
+	return loadBaseSnapshotFromDB()
+}
+
+// loadBaseSnapshotFromDB 取历史表中最新一天的 USD 基准快照
+func loadBaseSnapshotFromDB() (*BaseSnapshot, error) {
+	var rates []models.ExchangeRate
+	latestDate := global.Db.Model(&models.ExchangeRate{}).Select("MAX(date)").Where("from_currency = ?", "USD")
+	if err := global.Db.Where("from_currency = ? AND date = (?)", "USD", latestDate).Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, ErrRatesUnavailable
+	}
+	return snapshotFromRows(rates), nil
+}
+
+// LoadSnapshotAsOf 取历史表中日期不晚于 date 的最近一份 USD 基准快照
+func LoadSnapshotAsOf(date time.Time) (*BaseSnapshot, error) {
+	var rates []models.ExchangeRate
+	latestDate := global.Db.Model(&models.ExchangeRate{}).Select("MAX(date)").
+		Where("from_currency = ? AND date <= ?", "USD", SnapshotDate(date))
+	if err := global.Db.Where("from_currency = ? AND date = (?)", "USD", latestDate).Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, ErrRatesUnavailable
+	}
+	return snapshotFromRows(rates), nil
+}
+
+func snapshotFromRows(rates []models.ExchangeRate) *BaseSnapshot {
+	snapshot := &BaseSnapshot{Rates: make(map[string]float64, len(rates))}
+	for _, r := range rates {
+		snapshot.Rates[r.ToCurrency] = r.Rate
+		snapshot.Source = r.Source
+		snapshot.Date = r.Date
+		// 旧数据没有记录抓取时间，以日期代替
+		snapshot.FetchedAt = r.Date
+		if r.FetchedAt != nil {
+			snapshot.FetchedAt = *r.FetchedAt
+		}
+		if r.ProviderUpdatedAt != nil {
+			snapshot.UpdatedAt = *r.ProviderUpdatedAt
+		}
+	}
+	return snapshot
+}
+
+// applyMeta 解析 Redis 中的快照元信息
+func (s *BaseSnapshot) applyMeta(meta map[string]string) {
+	s.Source = meta["source"]
+	s.FetchedAt, _ = time.Parse(time.RFC3339, meta["fetchedAt"])
+	s.UpdatedAt, _ = time.Parse(time.RFC3339, meta["updatedAt"])
+	s.Date = SnapshotDate(s.FetchedAt)
+}
+
+// LoadBaseRatesFor 只读取指定货币的 USD 基准汇率：HMGET 与元信息在同一个 pipeline 中完成，
+// Redis 无数据时降级查库。快照中缺失的货币不会出现在返回的 Rates 中
+func LoadBaseRatesFor(ctx context.Context, codes []string) (*BaseSnapshot, error) {
+	if len(codes) == 0 {
+		return &BaseSnapshot{Rates: map[string]float64{}}, nil
+	}
+
+	pipe := global.RedisDB.Pipeline()
+	existsCmd := pipe.Exists(ctx, ExchangeRateRedisKey)
+	ratesCmd := pipe.HMGet(ctx, ExchangeRateRedisKey, codes...)
+	metaCmd := pipe.HGetAll(ctx, ExchangeRateMetaRedisKey)
+	if _, err := pipe.Exec(ctx); err == nil && existsCmd.Val() > 0 {
+		snapshot := &BaseSnapshot{Rates: make(map[string]float64, len(codes))}
+		for i, v := range ratesCmd.Val() {
+			valStr, ok := v.(string)
+			if !ok {
+				continue // 该货币不在快照中
+			}
+			rate, err := strconv.ParseFloat(valStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cached rate for %s: %w", codes[i], err)
+			}
+			snapshot.Rates[codes[i]] = rate
+		}
+		snapshot.applyMeta(metaCmd.Val())
+		return snapshot, nil
+	}
+
+	return loadBaseSnapshotFromDB()
+}
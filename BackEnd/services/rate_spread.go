@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SpreadCacheKey    = "rates:spreads"
+	SpreadCacheExpire = time.Minute // 多实例写入与读取交错时，最多在该时间内读到旧配置
+)
+
+// QuoteSide 换算使用的报价方向，针对 from 货币而言
+type QuoteSide string
+
+const (
+	SideMid  QuoteSide = "mid"  // 中间价
+	SideBuy  QuoteSide = "buy"  // 客户买入 from 货币，按卖出价 (ask) 计算需要支付的 to 金额
+	SideSell QuoteSide = "sell" // 客户卖出 from 货币，按买入价 (bid) 计算能得到的 to 金额
+
+	// SpreadMaxFraction 点差总宽度上限 (50%)，保证买入价为正
+	SpreadMaxFraction = 0.5
+)
+
+// ParseQuoteSide 解析报价方向，为空时使用中间价
+func ParseQuoteSide(s string) (QuoteSide, error) {
+	switch QuoteSide(s) {
+	case "", SideMid:
+		return SideMid, nil
+	case SideBuy, SideSell:
+		return QuoteSide(s), nil
+	}
+	return "", fmt.Errorf("unsupported side: %s", s)
+}
+
+// Spread 某个货币对实际使用的点差
+type Spread struct {
+	Scope string  `json:"scope"` // global / currency / pair，未配置时为空
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Fraction 点差总宽度占中间价的比例
+func (s Spread) Fraction() float64 {
+	if s.Unit == models.SpreadUnitPct {
+		return s.Value / 100
+	}
+	return s.Value / 10000
+}
+
+// Quote 由中间价按点差对称展开买入价和卖出价
+func (s Spread) Quote(mid float64) (bid, ask float64) {
+	half := s.Fraction() / 2
+	return mid * (1 - half), mid * (1 + half)
+}
+
+// QuoteRat 与 Quote 相同，但使用精确有理数且不做舍入，供金额换算使用
+func (s Spread) QuoteRat(mid *big.Rat) (bid, ask *big.Rat) {
+	half := new(big.Rat).Quo(RatFromFloat(s.Value), big.NewRat(2*10000, 1))
+	if s.Unit == models.SpreadUnitPct {
+		half = new(big.Rat).Quo(RatFromFloat(s.Value), big.NewRat(2*100, 1))
+	}
+	one := big.NewRat(1, 1)
+	bid = new(big.Rat).Mul(mid, new(big.Rat).Sub(one, half))
+	ask = new(big.Rat).Mul(mid, new(big.Rat).Add(one, half))
+	return bid, ask
+}
+
+// ValidateSpread 校验管理员提交的点差配置，并统一 Code 的大小写
+func ValidateSpread(s *models.RateSpread) error {
+	s.Code = strings.ToUpper(strings.TrimSpace(s.Code))
+	switch s.Scope {
+	case models.SpreadScopeGlobal:
+		s.Code = ""
+	case models.SpreadScopeCurrency:
+		if !IsKnownCurrency(s.Code) {
+			return fmt.Errorf("unknown currency: %s", s.Code)
+		}
+	case models.SpreadScopePair:
+		from, to, err := ParsePair(s.Code)
+		if err != nil {
+			return err
+		}
+		if !IsKnownCurrency(from) || !IsKnownCurrency(to) {
+			return fmt.Errorf("unknown currency in pair: %s", s.Code)
+		}
+		if from == to {
+			return errors.New("pair currencies must differ")
+		}
+		s.Code = from + "/" + to
+	default:
+		return fmt.Errorf("unknown scope: %s", s.Scope)
+	}
+
+	if s.Unit != models.SpreadUnitBps && s.Unit != models.SpreadUnitPct {
+		return fmt.Errorf("unknown unit: %s", s.Unit)
+	}
+	spread := Spread{Value: s.Value, Unit: s.Unit}
+	if s.Value < 0 || spread.Fraction() >= SpreadMaxFraction {
+		return fmt.Errorf("spread must be between 0 and %.0f%%", SpreadMaxFraction*100)
+	}
+	return nil
+}
+
+// SaveSpread 按 (scope, code) 新增或更新点差配置，s 回填为数据库中的记录
+// 更新已有记录时 MySQL 返回的 LastInsertId 并非该记录的 ID，需按 (scope, code) 重新读取
+func SaveSpread(s *models.RateSpread) error {
+	defer clearSpreadCache()
+	if err := global.Db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "unit", "updated_by", "updated_at"}),
+	}).Create(s).Error; err != nil {
+		return err
+	}
+
+	var saved models.RateSpread
+	if err := global.Db.Where("scope = ? AND code = ?", s.Scope, s.Code).First(&saved).Error; err != nil {
+		return err
+	}
+	*s = saved
+	return nil
+}
+
+// DeleteSpread 删除点差配置，不存在时返回 gorm.ErrRecordNotFound
+func DeleteSpread(id uint) error {
+	result := global.Db.Delete(&models.RateSpread{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	clearSpreadCache()
+	return nil
+}
+
+// clearSpreadCache 点差配置变更后清除缓存
+func clearSpreadCache() {
+	global.RedisDB.Del(context.Background(), SpreadCacheKey)
+}
+
+// SpreadSet 全部点差配置
+type SpreadSet struct {
+	global     *models.RateSpread
+	currencies map[string]*models.RateSpread
+	pairs      map[string]*models.RateSpread
+}
+
+// LoadSpreads 读取全部点差配置
+func LoadSpreads() (*SpreadSet, error) {
+	list, err := loadSpreadList()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &SpreadSet{currencies: make(map[string]*models.RateSpread), pairs: make(map[string]*models.RateSpread)}
+	for i := range list {
+		s := &list[i]
+		switch s.Scope {
+		case models.SpreadScopeGlobal:
+			set.global = s
+		case models.SpreadScopeCurrency:
+			set.currencies[s.Code] = s
+		case models.SpreadScopePair:
+			set.pairs[s.Code] = s
+		}
+	}
+	return set, nil
+}
+
+// loadSpreadList 读取全部点差配置，结果缓存在 Redis 中，保存或删除时失效
+func loadSpreadList() ([]models.RateSpread, error) {
+	ctx := context.Background()
+	if cached, err := global.RedisDB.Get(ctx, SpreadCacheKey).Result(); err == nil {
+		var list []models.RateSpread
+		if json.Unmarshal([]byte(cached), &list) == nil {
+			return list, nil
+		}
+	}
+
+	var list []models.RateSpread
+	if err := global.Db.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(list); err == nil {
+		global.RedisDB.Set(ctx, SpreadCacheKey, data, SpreadCacheExpire)
+	}
+	return list, nil
+}
+
+// For 返回 from/to 使用的点差，优先级：货币对 (含反向) > 单个货币 (两者取较宽者) > 全局默认
+// 都没有配置时点差为 0，买入价与卖出价等于中间价
+func (set *SpreadSet) For(from, to string) Spread {
+	if set == nil {
+		return Spread{Unit: models.SpreadUnitBps}
+	}
+	if s, ok := set.pairs[from+"/"+to]; ok {
+		return spreadOf(s)
+	}
+	if s, ok := set.pairs[to+"/"+from]; ok {
+		return spreadOf(s)
+	}
+
+	var best *Spread
+	for _, code := range []string{from, to} {
+		if s, ok := set.currencies[code]; ok {
+			candidate := spreadOf(s)
+			if best == nil || candidate.Fraction() > best.Fraction() {
+				best = &candidate
+			}
+		}
+	}
+	if best != nil {
+		return *best
+	}
+
+	if set.global != nil {
+		return spreadOf(set.global)
+	}
+	return Spread{Unit: models.SpreadUnitBps}
+}
+
+func spreadOf(s *models.RateSpread) Spread {
+	return Spread{Scope: s.Scope, Value: s.Value, Unit: s.Unit}
+}
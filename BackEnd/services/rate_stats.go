@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RateStatsCachePrefix = "rates:stats:"
+	// 缓存在每次刷新后重建，过期时间只作为刷新长期失败时的兜底
+	RateStatsCacheExpire = 25 * time.Hour
+
+	DefaultStatsWindow = 30
+	MaxStatsWindow     = 365
+
+	// 年化波动率按每年 252 个交易日折算
+	tradingDaysPerYear = 252
+)
+
+// 涨跌幅统计的回看区间
+var statsChangeDays = []int{1, 7, 30}
+
+// RateChange 某个回看区间内的涨跌
+type RateChange struct {
+	From time.Time `json:"from"` // 比较基准所在的日期
+	Abs  float64   `json:"abs"`
+	Pct  float64   `json:"pct"`
+}
+
+// RateStats 货币对在窗口期内的统计指标
+type RateStats struct {
+	Pair       string                 `json:"pair"`
+	Window     string                 `json:"window"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Points     int                    `json:"points"`
+	Current    float64                `json:"current"`
+	Change     map[string]*RateChange `json:"change"` // "1d" / "7d" / "30d"，历史不足时为 null
+	SMA        float64                `json:"sma"`
+	EMA        float64                `json:"ema"`
+	Min        RatePoint              `json:"min"`
+	Max        RatePoint              `json:"max"`
+	Volatility float64                `json:"volatility"` // 日对数收益率标准差 × √252
+	ComputedAt time.Time              `json:"computedAt"`
+}
+
+// ParseStatsWindow 解析 "30d" 形式的窗口，为空时默认 30 天
+func ParseStatsWindow(s string) (int, error) {
+	if s == "" {
+		return DefaultStatsWindow, nil
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+	if err != nil || !strings.HasSuffix(s, "d") || days < 2 || days > MaxStatsWindow {
+		return 0, fmt.Errorf("window must be between 2d and %dd", MaxStatsWindow)
+	}
+	return days, nil
+}
+
+// GetRateStats 读取统计指标，缓存未命中时根据历史表计算并写入缓存
+func GetRateStats(ctx context.Context, from, to string, window int) (*RateStats, error) {
+	cacheKey := rateStatsCacheKey(from, to, window)
+	if cached, err := global.RedisDB.Get(ctx, cacheKey).Result(); err == nil {
+		var stats RateStats
+		if json.Unmarshal([]byte(cached), &stats) == nil {
+			return &stats, nil
+		}
+	}
+
+	stats, err := ComputeRateStats(from, to, window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(stats); err == nil {
+		global.RedisDB.Set(ctx, cacheKey, data, RateStatsCacheExpire)
+	}
+	return stats, nil
+}
+
+// ComputeRateStats 根据历史快照计算截至 end 的统计指标
+func ComputeRateStats(from, to string, window int, end time.Time) (*RateStats, error) {
+	lookback := window
+	if maxChange := statsChangeDays[len(statsChangeDays)-1]; lookback < maxChange {
+		lookback = maxChange
+	}
+	// 多取几天，保证回看区间起点前有数据可比 (周末、节假日可能缺数据)
+	series, err := GetCrossRateHistory(from, to, end.AddDate(0, 0, -lookback-7), end)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return nil, ErrRatesUnavailable
+	}
+
+	last := series[len(series)-1]
+	start := SnapshotDate(last.Date).AddDate(0, 0, -window)
+	stats := &RateStats{
+		Pair:       from + "/" + to,
+		Window:     strconv.Itoa(window) + "d",
+		Start:      start,
+		End:        last.Date,
+		Current:    last.Rate,
+		Change:     make(map[string]*RateChange, len(statsChangeDays)),
+		ComputedAt: time.Now(),
+	}
+
+	for _, days := range statsChangeDays {
+		stats.Change[strconv.Itoa(days)+"d"] = rateChangeSince(series, last, days)
+	}
+
+	// 窗口内的数据点
+	var windowPoints []RatePoint
+	for _, p := range series {
+		if !p.Date.Before(start) {
+			windowPoints = append(windowPoints, p)
+		}
+	}
+	stats.Points = len(windowPoints)
+	stats.Min, stats.Max = windowPoints[0], windowPoints[0]
+
+	alpha := 2 / (float64(len(windowPoints)) + 1)
+	sum, ema := 0.0, windowPoints[0].Rate
+	for i, p := range windowPoints {
+		sum += p.Rate
+		if i > 0 {
+			ema = alpha*p.Rate + (1-alpha)*ema
+		}
+		if p.Rate < stats.Min.Rate {
+			stats.Min = p
+		}
+		if p.Rate > stats.Max.Rate {
+			stats.Max = p
+		}
+	}
+	stats.SMA = sum / float64(len(windowPoints))
+	stats.EMA = ema
+	stats.Volatility = annualizedVolatility(windowPoints)
+	return stats, nil
+}
+
+// rateChangeSince 与 days 天前 (或之前最近一天) 的汇率比较，历史不足时返回 nil
+func rateChangeSince(series []RatePoint, last RatePoint, days int) *RateChange {
+	target := SnapshotDate(last.Date).AddDate(0, 0, -days)
+	for i := len(series) - 1; i >= 0; i-- {
+		p := series[i]
+		if p.Date.After(target) {
+			continue
+		}
+		if p.Rate <= 0 {
+			break
+		}
+		return &RateChange{From: p.Date, Abs: last.Rate - p.Rate, Pct: (last.Rate/p.Rate - 1) * 100}
+	}
+	return nil
+}
+
+// annualizedVolatility 日对数收益率的样本标准差，按交易日年化
+func annualizedVolatility(points []RatePoint) float64 {
+	if len(points) < 3 {
+		return 0
+	}
+	returns := make([]float64, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		if points[i-1].Rate > 0 && points[i].Rate > 0 {
+			returns = append(returns, math.Log(points[i].Rate/points[i-1].Rate))
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+	return math.Sqrt(variance) * math.Sqrt(tradingDaysPerYear)
+}
+
+// PrecomputeRateStats 刷新后清空统计缓存，并为配置中的常用货币对预先计算默认窗口的指标
+func PrecomputeRateStats(ctx context.Context) {
+	clearCacheByPattern(ctx, RateStatsCachePrefix+"*")
+
+	var errs []error
+	for _, pair := range config.AppConfig.ExchangeRate.StatsPairs {
+		from, to, err := ParsePair(pair)
+		if err == nil {
+			_, err = GetRateStats(ctx, from, to, DefaultStatsWindow)
+		}
+		if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+			errs = append(errs, fmt.Errorf("%s: %w", pair, err))
+		}
+	}
+	if len(errs) > 0 {
+		log.Printf("Precomputing rate stats failed: %v\n", errors.Join(errs...))
+	}
+}
+
+func rateStatsCacheKey(from, to string, window int) string {
+	return fmt.Sprintf("%s%s/%s:%dd", RateStatsCachePrefix, from, to, window)
+}
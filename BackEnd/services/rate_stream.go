@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"exchangeapp/global"
+	"log"
+	"sync"
+	"time"
+)
+
+const RateUpdateChannel = "rates:updates" // Redis pub/sub 频道，所有副本都订阅
+
+// RateUpdateEvent 调度器每次写入新快照后发布的事件
+type RateUpdateEvent struct {
+	Source    string             `json:"source"`
+	FetchedAt time.Time          `json:"fetchedAt"`
+	Rates     map[string]float64 `json:"rates"`   // 完整的 USD 基准汇率
+	Changed   []string           `json:"changed"` // 相比上一份快照发生变化的货币
+}
+
+// RateDiff 推送给单个客户端的增量：只包含其关心且发生变化的货币对
+type RateDiff struct {
+	Source    string             `json:"source"`
+	FetchedAt time.Time          `json:"fetchedAt"`
+	Rates     map[string]float64 `json:"rates"` // "FROM/TO" -> rate
+}
+
+// RateSubscriber 一个流式连接的订阅
+type RateSubscriber struct {
+	C     chan RateDiff
+	pairs [][2]string // 为空时订阅全部 USD 基准汇率
+}
+
+var rateHub = struct {
+	mu          sync.RWMutex
+	subscribers map[*RateSubscriber]struct{}
+}{subscribers: make(map[*RateSubscriber]struct{})}
+
+// PublishRateUpdate 将快照变化发布到 Redis，由各副本的 hub 转发给本机连接
+func PublishRateUpdate(ctx context.Context, previous, current map[string]float64, source string, fetchedAt time.Time) error {
+	var changed []string
+	for code, rate := range current {
+		if prev, ok := previous[code]; !ok || prev != rate {
+			changed = append(changed, code)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(RateUpdateEvent{Source: source, FetchedAt: fetchedAt, Rates: current, Changed: changed})
+	if err != nil {
+		return err
+	}
+	return global.RedisDB.Publish(ctx, RateUpdateChannel, data).Err()
+}
+
+// StartRateHub 订阅 Redis 频道并分发给本进程内的所有订阅者，ctx 取消时退出
+func StartRateHub(ctx context.Context) {
+	pubsub := global.RedisDB.Subscribe(ctx, RateUpdateChannel)
+
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var event RateUpdateEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Printf("Invalid rate update event: %v\n", err)
+					continue
+				}
+				dispatchRateUpdate(&event)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// SubscribeRates 注册一个订阅，pairs 为 "FROM/TO" 列表，为空表示订阅全部
+func SubscribeRates(pairs []string) (*RateSubscriber, error) {
+	sub := &RateSubscriber{C: make(chan RateDiff, 8)}
+	for _, p := range pairs {
+		from, to, err := ParsePair(p)
+		if err != nil {
+			return nil, err
+		}
+		sub.pairs = append(sub.pairs, [2]string{from, to})
+	}
+
+	rateHub.mu.Lock()
+	rateHub.subscribers[sub] = struct{}{}
+	rateHub.mu.Unlock()
+	return sub, nil
+}
+
+// UnsubscribeRates 取消订阅，连接关闭时调用
+func UnsubscribeRates(sub *RateSubscriber) {
+	rateHub.mu.Lock()
+	delete(rateHub.subscribers, sub)
+	rateHub.mu.Unlock()
+}
+
+// Snapshot 按订阅范围生成当前快照的完整视图，用于连接建立时下发初始数据
+func (sub *RateSubscriber) Snapshot(snapshot *BaseSnapshot) RateDiff {
+	all := make(map[string]bool, len(snapshot.Rates))
+	for code := range snapshot.Rates {
+		all[code] = true
+	}
+	return sub.diff(snapshot, all)
+}
+
+func (sub *RateSubscriber) diff(snapshot *BaseSnapshot, changed map[string]bool) RateDiff {
+	diff := RateDiff{Source: snapshot.Source, FetchedAt: snapshot.FetchedAt, Rates: make(map[string]float64)}
+	if len(sub.pairs) == 0 {
+		for code := range changed {
+			if rate, ok := snapshot.USDRate(code); ok {
+				diff.Rates["USD/"+code] = rate
+			}
+		}
+		return diff
+	}
+
+	// 交叉汇率的任一条腿变化都会导致该货币对变化
+	for _, p := range sub.pairs {
+		if !changed[p[0]] && !changed[p[1]] {
+			continue
+		}
+		if rate, ok := pairRate(snapshot, p[0], p[1]); ok {
+			diff.Rates[p[0]+"/"+p[1]] = rate
+		}
+	}
+	return diff
+}
+
+func dispatchRateUpdate(event *RateUpdateEvent) {
+	snapshot := &BaseSnapshot{Rates: event.Rates, Source: event.Source, FetchedAt: event.FetchedAt}
+	changed := make(map[string]bool, len(event.Changed))
+	for _, code := range event.Changed {
+		changed[code] = true
+	}
+
+	rateHub.mu.RLock()
+	defer rateHub.mu.RUnlock()
+	for sub := range rateHub.subscribers {
+		diff := sub.diff(snapshot, changed)
+		if len(diff.Rates) == 0 {
+			continue
+		}
+		// 慢客户端不阻塞其他连接，缓冲区满时丢弃本次推送
+		select {
+		case sub.C <- diff:
+		default:
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSnapshotQuarantined = errors.New("rate snapshot quarantined")
+	ErrQuarantineReviewed  = errors.New("quarantined snapshot already reviewed")
+)
+
+// ValidateSnapshot 按配置的规则校验新快照，返回未通过的原因；previous 为空或不适合作为比较基准时只做基本检查
+func ValidateSnapshot(snapshot *RateSnapshot, previous *BaseSnapshot) []string {
+	rules := config.AppConfig.ExchangeRate.Validation
+	var reasons []string
+
+	// 1. 非正数 / 非法数值
+	if invalid := invalidRates(snapshot.Rates); len(invalid) > 0 {
+		reasons = append(reasons, fmt.Sprintf("non-positive rates: %s", strings.Join(invalid, ",")))
+	}
+
+	// 2. 货币数量
+	if rules.MinCurrencies > 0 && len(snapshot.Rates) < rules.MinCurrencies {
+		reasons = append(reasons, fmt.Sprintf("only %d currencies, minimum is %d", len(snapshot.Rates), rules.MinCurrencies))
+	}
+
+	if !comparableBaseline(previous, time.Now()) {
+		return reasons
+	}
+
+	// 3. 覆盖率：上一份快照中的货币在新快照中缺失太多
+	var missing []string
+	for code := range previous.Rates {
+		if _, ok := snapshot.Rates[code]; !ok {
+			missing = append(missing, code)
+		}
+	}
+	coverage := 1 - float64(len(missing))/float64(len(previous.Rates))
+	if rules.MinCoverage > 0 && coverage < rules.MinCoverage {
+		sort.Strings(missing)
+		reasons = append(reasons, fmt.Sprintf("coverage %.0f%% below %.0f%%, missing: %s", coverage*100, rules.MinCoverage*100, strings.Join(missing, ",")))
+	}
+
+	// 4. 单个货币相对上一份快照的跳变
+	if rules.MaxChangePct > 0 {
+		var jumps []string
+		for code, rate := range snapshot.Rates {
+			prev, ok := previous.Rates[code]
+			if !ok || prev <= 0 || !(rate > 0) {
+				continue
+			}
+			if change := (rate/prev - 1) * 100; math.Abs(change) > rules.MaxChangePct {
+				jumps = append(jumps, fmt.Sprintf("%s %+.2f%%", code, change))
+			}
+		}
+		if len(jumps) > 0 {
+			sort.Strings(jumps)
+			reasons = append(reasons, fmt.Sprintf("change exceeds %.2f%%: %s", rules.MaxChangePct, strings.Join(jumps, ", ")))
+		}
+	}
+
+	return reasons
+}
+
+// invalidRates 返回非正数、NaN 或无穷大的汇率对应的货币，按代码排序
+func invalidRates(rates map[string]float64) []string {
+	var invalid []string
+	for code, rate := range rates {
+		if !(rate > 0) || math.IsInf(rate, 0) {
+			invalid = append(invalid, code)
+		}
+	}
+	sort.Strings(invalid)
+	return invalid
+}
+
+// comparableBaseline 上一份快照能否作为覆盖率与跳变规则的基准：
+// 内置或导入的快照只是近似值，过旧的快照与实时数据的差异本就可能超过阈值，都会导致新数据被一直隔离
+func comparableBaseline(previous *BaseSnapshot, now time.Time) bool {
+	if previous == nil || len(previous.Rates) == 0 {
+		return false
+	}
+	if previous.Source == SeedSource || previous.Source == ImportSource {
+		return false
+	}
+	maxAge := config.AppConfig.ExchangeRate.Validation.MaxBaselineAge
+	return maxAge <= 0 || previous.Date.IsZero() || now.Sub(previous.Date) <= maxAge
+}
+
+// QuarantineSnapshot 将未通过校验的快照写入隔离表
+func QuarantineSnapshot(snapshot *RateSnapshot, fetchedAt time.Time, reasons []string) (*models.RateQuarantine, error) {
+	rates, err := json.Marshal(snapshot.Rates)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &models.RateQuarantine{
+		Source:    snapshot.Provider,
+		FetchedAt: fetchedAt,
+		Rates:     string(rates),
+		Reasons:   strings.Join(reasons, "\n"),
+		Status:    models.QuarantinePending,
+	}
+	if !snapshot.UpdatedAt.IsZero() {
+		q.ProviderUpdatedAt = &snapshot.UpdatedAt
+	}
+	if err := global.Db.Create(q).Error; err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// ApproveQuarantine 管理员确认隔离的快照无误，按原抓取时间生效
+func ApproveQuarantine(ctx context.Context, id uint, reviewer string) (*models.RateQuarantine, error) {
+	var q models.RateQuarantine
+	if err := global.Db.First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	if q.Status != models.QuarantinePending {
+		return nil, ErrQuarantineReviewed
+	}
+
+	snapshot := &RateSnapshot{Base: "USD", Provider: q.Source}
+	if q.ProviderUpdatedAt != nil {
+		snapshot.UpdatedAt = *q.ProviderUpdatedAt
+	}
+	if err := json.Unmarshal([]byte(q.Rates), &snapshot.Rates); err != nil {
+		return nil, err
+	}
+
+	// 与定时刷新互斥，避免两份快照交错写入
+	err := WithLeaseLock(ctx, RateRefreshLock, RateRefreshLeaseTTL, func(ctx context.Context) error {
+		// 先认领记录再生效，并发的批准或驳回只有一个能成功
+		if err := reviewQuarantine(&q, models.QuarantineApproved, reviewer); err != nil {
+			return err
+		}
+
+		err := func() error {
+			previous, err := LoadBaseSnapshot(ctx)
+			if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+				return err
+			}
+			// 隔离期间已有更新的快照生效时，只补录历史，不覆盖当前汇率
+			if previous != nil && previous.FetchedAt.After(q.FetchedAt) {
+				return saveSnapshotHistory(global.Db, snapshot, SnapshotDate(q.FetchedAt), q.FetchedAt)
+			}
+			return applySnapshot(ctx, snapshot, previous, q.FetchedAt)
+		}()
+		if err != nil {
+			// 生效失败时退回待审核，管理员可以重试
+			if revertErr := reopenQuarantine(&q); revertErr != nil {
+				log.Printf("Reopening quarantine %d failed: %v\n", q.ID, revertErr)
+			}
+		}
+		return err
+	})
+	if errors.Is(err, ErrLockHeld) {
+		return nil, ErrRefreshInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// RejectQuarantine 管理员驳回隔离的快照
+func RejectQuarantine(id uint, reviewer string) (*models.RateQuarantine, error) {
+	var q models.RateQuarantine
+	if err := global.Db.First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	if q.Status != models.QuarantinePending {
+		return nil, ErrQuarantineReviewed
+	}
+	if err := reviewQuarantine(&q, models.QuarantineRejected, reviewer); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// reviewQuarantine 仅当记录仍为待审核时写入审核结果，已被其他请求审核时返回 ErrQuarantineReviewed
+func reviewQuarantine(q *models.RateQuarantine, status, reviewer string) error {
+	now := time.Now()
+	result := global.Db.Model(&models.RateQuarantine{}).
+		Where("id = ? AND status = ?", q.ID, models.QuarantinePending).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": reviewer, "reviewed_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuarantineReviewed
+	}
+	q.Status = status
+	q.ReviewedBy = reviewer
+	q.ReviewedAt = &now
+	return nil
+}
+
+// reopenQuarantine 撤销 reviewQuarantine 写入的审核结果
+func reopenQuarantine(q *models.RateQuarantine) error {
+	err := global.Db.Model(&models.RateQuarantine{}).
+		Where("id = ? AND status = ?", q.ID, q.Status).
+		Updates(map[string]interface{}{"status": models.QuarantinePending, "reviewed_by": "", "reviewed_at": nil}).Error
+	if err != nil {
+		return err
+	}
+	q.Status = models.QuarantinePending
+	q.ReviewedBy = ""
+	q.ReviewedAt = nil
+	return nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"exchangeapp/config"
+	"testing"
+	"time"
+)
+
+func TestValidateSnapshotBaseline(t *testing.T) {
+	config.AppConfig = &config.Config{}
+	rules := &config.AppConfig.ExchangeRate.Validation
+	rules.MaxChangePct = 20
+	rules.MinCoverage = 0.9
+	rules.MaxBaselineAge = 7 * 24 * time.Hour
+
+	// 与基准相比 CNY 跳变 40%，并缺失 JPY、GBP
+	snapshot := &RateSnapshot{Base: "USD", Rates: map[string]float64{"CNY": 10, "EUR": 0.91}}
+	baseline := map[string]float64{"CNY": 7.1, "EUR": 0.91, "JPY": 141, "GBP": 0.79}
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		previous *BaseSnapshot
+		wantFail bool
+	}{
+		{"live snapshot", &BaseSnapshot{Rates: baseline, Source: "exchangerate-api", Date: SnapshotDate(now)}, true},
+		{"unknown date", &BaseSnapshot{Rates: baseline, Source: "exchangerate-api"}, true},
+		{"bundled seed", &BaseSnapshot{Rates: baseline, Source: SeedSource, Date: SnapshotDate(now)}, false},
+		{"imported file", &BaseSnapshot{Rates: baseline, Source: ImportSource, Date: SnapshotDate(now)}, false},
+		{"outdated", &BaseSnapshot{Rates: baseline, Source: "exchangerate-api", Date: now.AddDate(0, 0, -30)}, false},
+		{"no previous", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := ValidateSnapshot(snapshot, tt.previous)
+			if failed := len(reasons) > 0; failed != tt.wantFail {
+				t.Errorf("reasons = %v, want failure %v", reasons, tt.wantFail)
+			}
+		})
+	}
+}
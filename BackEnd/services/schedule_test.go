@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	tests := []struct {
+		cron     string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{"0 8 * * 1-5", 0, false},
+		{"0 0 29 2 *", 0, false}, // 闰日在 5 年内必然出现
+		{"0 0 31 2 *", 0, true},  // 永远不会触发
+		{"0 0 31 4,6 *", 0, true},
+		{"bad", 0, true},
+		{"", time.Hour, false},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		s, err := NewSchedule(tt.cron, tt.interval)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewSchedule(%q, %s) err = %v, wantErr %v", tt.cron, tt.interval, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s.Next(time.Now()).IsZero() {
+			t.Errorf("NewSchedule(%q, %s) has no next run", tt.cron, tt.interval)
+		}
+	}
+}
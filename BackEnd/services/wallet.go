@@ -0,0 +1,467 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/config"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InitialDepositKey 开户资金使用的幂等键，保证每个用户只发放一次
+const InitialDepositKey = "initial-deposit"
+
+var (
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrIdempotencyConflict = errors.New("idempotency key reused with different parameters")
+	ErrQuoteStale          = errors.New("rate snapshot is stale, trading is paused")
+	ErrInvalidAmount       = errors.New("invalid amount")
+)
+
+// ======================= 钱包 =========================
+
+// GetWallets 返回用户全部钱包，首次访问时发放初始资金
+func GetWallets(userID uint) ([]models.Wallet, error) {
+	if err := ensureInitialDeposit(userID); err != nil {
+		return nil, err
+	}
+
+	var wallets []models.Wallet
+	if err := global.Db.Where("user_id = ?", userID).Order("currency").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// ensureInitialDeposit 从对手方账户向用户的初始币种钱包发放虚拟资金
+// 并发的首次请求依赖 (user_id, idempotency_key) 唯一索引保证只发放一次
+func ensureInitialDeposit(userID uint) error {
+	var count int64
+	if err := global.Db.Model(&models.WalletTransaction{}).
+		Where("user_id = ? AND idempotency_key = ?", userID, InitialDepositKey).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	cfg := config.AppConfig.PaperTrading
+	currency := cfg.StartingCurrency
+	amount, err := ParseDecimal(cfg.StartingBalance)
+	if err != nil {
+		return fmt.Errorf("paperTrading.startingBalance: %w", err)
+	}
+	amount = RoundRat(amount, MinorUnits(currency), RoundHalfEven)
+
+	err = global.Db.Transaction(func(tx *gorm.DB) error {
+		wallets, err := lockWallets(tx, userID, currency)
+		if err != nil {
+			return err
+		}
+		wallet := wallets[currency]
+
+		record := &models.WalletTransaction{
+			UserID:         userID,
+			IdempotencyKey: InitialDepositKey,
+			Type:           models.WalletTxDeposit,
+			ToCurrency:     currency,
+			ToAmount:       formatAmount(amount, currency),
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if err := credit(tx, wallet, amount); err != nil {
+			return err
+		}
+		return postEntries(tx, record.ID, []ledgerLine{
+			{account: models.LedgerAccountHouse, currency: currency, amount: new(big.Rat).Neg(amount)},
+			{account: userAccount(userID), wallet: wallet, currency: currency, amount: amount},
+		})
+	})
+	if err == nil {
+		return nil
+	}
+
+	// 并发请求已经发放过
+	if global.Db.Model(&models.WalletTransaction{}).
+		Where("user_id = ? AND idempotency_key = ?", userID, InitialDepositKey).Count(&count).Error == nil && count > 0 {
+		return nil
+	}
+	return err
+}
+
+// ======================= 兑换 =========================
+
+// ExchangeRequest 一次兑换请求：卖出 Amount 的 From，按买入价 (bid) 换成 To
+type ExchangeRequest struct {
+	From           string
+	To             string
+	Amount         string
+	IdempotencyKey string
+}
+
+// ExecuteExchange 以当前报价 (含点差) 执行兑换并记入复式账
+// 同一幂等键重复提交时返回已有交易且 replayed 为 true；参数不同则返回 ErrIdempotencyConflict
+func ExecuteExchange(ctx context.Context, userID uint, req ExchangeRequest) (record *models.WalletTransaction, replayed bool, err error) {
+	if existing, err := findTransaction(userID, req.IdempotencyKey); err != nil || existing != nil {
+		if err != nil {
+			return nil, false, err
+		}
+		if !sameExchange(existing, req) {
+			return nil, false, ErrIdempotencyConflict
+		}
+		return existing, true, nil
+	}
+
+	amount, err := ParseDecimal(req.Amount)
+	if err != nil || amount.Sign() <= 0 {
+		return nil, false, ErrInvalidAmount
+	}
+	// 金额不能超过卖出币种的最小单位
+	if RoundRat(amount, MinorUnits(req.From), RoundHalfEven).Cmp(amount) != 0 {
+		return nil, false, fmt.Errorf("%w: at most %d decimal places for %s", ErrInvalidAmount, MinorUnits(req.From), req.From)
+	}
+	if err := ensureInitialDeposit(userID); err != nil {
+		return nil, false, err
+	}
+
+	// 报价：人工汇率优先，按点差取买入价
+	now := time.Now()
+	snapshot, err := LoadBaseSnapshot(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if snapshot.Stale(now) {
+		return nil, false, ErrQuoteStale
+	}
+	overrides, err := LoadActiveOverrides(now)
+	if err != nil {
+		return nil, false, err
+	}
+	spreads, err := LoadSpreads()
+	if err != nil {
+		return nil, false, err
+	}
+	usdToFrom, usdToTo, _, ok := overrides.Legs(snapshot, req.From, req.To)
+	if !ok {
+		return nil, false, fmt.Errorf("no rate for %s/%s", req.From, req.To)
+	}
+	quote, err := ConvertAmount(req.From, req.To, req.Amount, usdToFrom, usdToTo, spreads.For(req.From, req.To), SideSell, RoundHalfEven)
+	if err != nil {
+		return nil, false, err
+	}
+	received, _ := ParseDecimal(quote.ConvertedAmount)
+	if received.Sign() <= 0 {
+		return nil, false, fmt.Errorf("%w: amount too small", ErrInvalidAmount)
+	}
+
+	asOf := snapshot.AsOf()
+	record = &models.WalletTransaction{
+		UserID:         userID,
+		IdempotencyKey: req.IdempotencyKey,
+		Type:           models.WalletTxExchange,
+		FromCurrency:   req.From,
+		FromAmount:     formatAmount(amount, req.From),
+		ToCurrency:     req.To,
+		ToAmount:       quote.ConvertedAmount,
+		Rate:           quote.Rate,
+		MidRate:        quote.Mid,
+		Source:         snapshot.Source,
+		RateAsOf:       &asOf,
+	}
+
+	err = global.Db.Transaction(func(tx *gorm.DB) error {
+		// 行锁保证并发请求下余额一致
+		wallets, err := lockWallets(tx, userID, req.From, req.To)
+		if err != nil {
+			return err
+		}
+		fromWallet, toWallet := wallets[req.From], wallets[req.To]
+
+		balance, err := ParseDecimal(fromWallet.Balance)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(amount) < 0 {
+			return ErrInsufficientFunds
+		}
+
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if err := credit(tx, fromWallet, new(big.Rat).Neg(amount)); err != nil {
+			return err
+		}
+		if err := credit(tx, toWallet, received); err != nil {
+			return err
+		}
+		// 用户卖出 from 给对手方，对手方支付 to 给用户，每个币种借贷相抵
+		return postEntries(tx, record.ID, []ledgerLine{
+			{account: userAccount(userID), wallet: fromWallet, currency: req.From, amount: new(big.Rat).Neg(amount)},
+			{account: models.LedgerAccountHouse, currency: req.From, amount: amount},
+			{account: models.LedgerAccountHouse, currency: req.To, amount: new(big.Rat).Neg(received)},
+			{account: userAccount(userID), wallet: toWallet, currency: req.To, amount: received},
+		})
+	})
+	if err != nil {
+		// 同一幂等键的并发请求：唯一索引冲突，返回先提交的那一笔
+		if existing, findErr := findTransaction(userID, req.IdempotencyKey); findErr == nil && existing != nil {
+			if !sameExchange(existing, req) {
+				return nil, false, ErrIdempotencyConflict
+			}
+			return existing, true, nil
+		}
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func findTransaction(userID uint, key string) (*models.WalletTransaction, error) {
+	var record models.WalletTransaction
+	err := global.Db.Preload("Entries").Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func sameExchange(record *models.WalletTransaction, req ExchangeRequest) bool {
+	if record.Type != models.WalletTxExchange || record.FromCurrency != req.From || record.ToCurrency != req.To {
+		return false
+	}
+	stored, err1 := ParseDecimal(record.FromAmount)
+	requested, err2 := ParseDecimal(req.Amount)
+	return err1 == nil && err2 == nil && stored.Cmp(requested) == 0
+}
+
+// lockWallets 确保钱包存在，并按币种顺序加行锁 (固定顺序避免死锁)
+func lockWallets(tx *gorm.DB, userID uint, currencies ...string) (map[string]*models.Wallet, error) {
+	seed := make([]models.Wallet, 0, len(currencies))
+	for _, c := range currencies {
+		seed = append(seed, models.Wallet{UserID: userID, Currency: c, Balance: "0"})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+		return nil, err
+	}
+
+	sorted := append([]string(nil), currencies...)
+	sort.Strings(sorted)
+	var wallets []models.Wallet
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency IN ?", userID, sorted).Order("currency").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*models.Wallet, len(wallets))
+	for i := range wallets {
+		result[wallets[i].Currency] = &wallets[i]
+	}
+	for _, c := range currencies {
+		if result[c] == nil {
+			return nil, fmt.Errorf("wallet %s not found", c)
+		}
+	}
+	return result, nil
+}
+
+// credit 调整钱包余额，delta 为负数时表示扣款
+func credit(tx *gorm.DB, wallet *models.Wallet, delta *big.Rat) error {
+	balance, err := ParseDecimal(wallet.Balance)
+	if err != nil {
+		return err
+	}
+	wallet.Balance = formatAmount(balance.Add(balance, delta), wallet.Currency)
+	return tx.Model(wallet).Update("balance", wallet.Balance).Error
+}
+
+type ledgerLine struct {
+	account  string
+	wallet   *models.Wallet
+	currency string
+	amount   *big.Rat
+}
+
+// postEntries 写入复式记账分录，写入前校验每个币种借贷相抵
+func postEntries(tx *gorm.DB, transactionID uint, lines []ledgerLine) error {
+	sums := make(map[string]*big.Rat)
+	entries := make([]models.LedgerEntry, 0, len(lines))
+	for _, l := range lines {
+		if sums[l.currency] == nil {
+			sums[l.currency] = new(big.Rat)
+		}
+		sums[l.currency].Add(sums[l.currency], l.amount)
+
+		entry := models.LedgerEntry{
+			TransactionID: transactionID,
+			Account:       l.account,
+			Currency:      l.currency,
+			Amount:        formatAmount(l.amount, l.currency),
+		}
+		if l.wallet != nil {
+			entry.WalletID = &l.wallet.ID
+		}
+		entries = append(entries, entry)
+	}
+	for currency, sum := range sums {
+		if sum.Sign() != 0 {
+			return fmt.Errorf("unbalanced ledger entries for %s", currency)
+		}
+	}
+	return tx.Create(&entries).Error
+}
+
+func userAccount(userID uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func formatAmount(r *big.Rat, currency string) string {
+	return r.FloatString(MinorUnits(currency))
+}
+
+// ======================= 估值与盈亏 =========================
+
+// WalletValue 单个钱包按本位币估值
+type WalletValue struct {
+	models.Wallet
+	Value string `json:"value,omitempty"` // 按当前中间价折算的本位币金额，无汇率时为空
+}
+
+// Portfolio 用户全部钱包的估值汇总
+type Portfolio struct {
+	HomeCurrency string        `json:"homeCurrency"`
+	Wallets      []WalletValue `json:"wallets"`
+	TotalValue   string        `json:"totalValue"` // 全部钱包的本位币估值
+	Deposited    string        `json:"deposited"`  // 累计发放的资金按当前中间价折算
+	PnL          string        `json:"pnl"`        // TotalValue - Deposited
+	AsOf         time.Time     `json:"asOf"`
+	Stale        bool          `json:"stale"`
+}
+
+// TransactionWithPnL 交易记录及其按本位币计算的盈亏
+// 兑换的盈亏 = 收到的金额按当前中间价折算 - 付出的金额按当前中间价折算
+type TransactionWithPnL struct {
+	models.WalletTransaction
+	PnL string `json:"pnl,omitempty"`
+}
+
+// valuer 按同一份快照把任意币种金额折算为本位币
+type valuer struct {
+	snapshot  *BaseSnapshot
+	overrides *OverrideSet
+	home      string
+}
+
+func newValuer(ctx context.Context, home string) (*valuer, error) {
+	snapshot, err := LoadBaseSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := LoadActiveOverrides(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &valuer{snapshot: snapshot, overrides: overrides, home: home}, nil
+}
+
+func (v *valuer) value(amount, currency string) (*big.Rat, bool) {
+	a, err := ParseDecimal(amount)
+	if err != nil {
+		return nil, false
+	}
+	if currency == v.home {
+		return a, true
+	}
+	usdToFrom, usdToTo, _, ok := v.overrides.Legs(v.snapshot, currency, v.home)
+	if !ok || usdToFrom <= 0 {
+		return nil, false
+	}
+	rate := RoundRat(new(big.Rat).Quo(RatFromFloat(usdToTo), RatFromFloat(usdToFrom)), RatePrecision, RoundHalfEven)
+	return a.Mul(a, rate), true
+}
+
+func (v *valuer) format(r *big.Rat) string {
+	return RoundRat(r, MinorUnits(v.home), RoundHalfEven).FloatString(MinorUnits(v.home))
+}
+
+// GetPortfolio 按本位币汇总钱包估值和整体盈亏
+func GetPortfolio(ctx context.Context, userID uint, home string) (*Portfolio, error) {
+	wallets, err := GetWallets(userID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := newValuer(ctx, home)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Portfolio{HomeCurrency: home, Wallets: make([]WalletValue, len(wallets)), AsOf: v.snapshot.AsOf(), Stale: v.snapshot.Stale(time.Now())}
+	total := new(big.Rat)
+	for i, w := range wallets {
+		p.Wallets[i].Wallet = w
+		if value, ok := v.value(w.Balance, w.Currency); ok {
+			p.Wallets[i].Value = v.format(value)
+			total.Add(total, value)
+		}
+	}
+
+	var deposits []models.WalletTransaction
+	if err := global.Db.Where("user_id = ? AND type = ?", userID, models.WalletTxDeposit).Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	deposited := new(big.Rat)
+	for _, d := range deposits {
+		if value, ok := v.value(d.ToAmount, d.ToCurrency); ok {
+			deposited.Add(deposited, value)
+		}
+	}
+
+	p.TotalValue = v.format(total)
+	p.Deposited = v.format(deposited)
+	p.PnL = v.format(new(big.Rat).Sub(total, deposited))
+	return p, nil
+}
+
+// GetTransactionHistory 分页返回交易记录 (含分录)，兑换记录附带按本位币计算的盈亏
+func GetTransactionHistory(ctx context.Context, userID uint, home string, page, pageSize int) ([]TransactionWithPnL, int64, error) {
+	var total int64
+	query := global.Db.Model(&models.WalletTransaction{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []models.WalletTransaction
+	if err := query.Preload("Entries").Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+
+	v, err := newValuer(ctx, home)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]TransactionWithPnL, len(records))
+	for i, r := range records {
+		result[i].WalletTransaction = r
+		if r.Type != models.WalletTxExchange {
+			continue
+		}
+		received, ok1 := v.value(r.ToAmount, r.ToCurrency)
+		paid, ok2 := v.value(r.FromAmount, r.FromCurrency)
+		if ok1 && ok2 {
+			result[i].PnL = v.format(new(big.Rat).Sub(received, paid))
+		}
+	}
+	return result, total, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"exchangeapp/global"
+	"exchangeapp/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WatchlistMaxEntries 每个用户最多自选的货币对数量
+const WatchlistMaxEntries = 50
+
+var (
+	ErrWatchlistFull      = errors.New("watchlist is full")
+	ErrWatchlistDuplicate = errors.New("pair already in watchlist")
+	ErrWatchlistOrder     = errors.New("order must list every entry exactly once")
+)
+
+// GetWatchlist 返回用户的自选列表，不存在时自动创建；条目按 Position 排序
+func GetWatchlist(userID uint) (*models.Watchlist, error) {
+	list, err := loadWatchlist(userID)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return list, err
+	}
+
+	// 同一用户并发的首次请求可能同时创建，user_id 唯一索引冲突时忽略，再统一读取
+	if err := global.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Watchlist{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+	return loadWatchlist(userID)
+}
+
+func loadWatchlist(userID uint) (*models.Watchlist, error) {
+	var list models.Watchlist
+	err := global.Db.Where("user_id = ?", userID).
+		Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		First(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// AddWatchlistEntry 把货币对追加到自选列表末尾
+func AddWatchlistEntry(userID uint, from, to string) (*models.WatchlistEntry, error) {
+	list, err := GetWatchlist(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.WatchlistEntry{WatchlistID: list.ID, FromCurrency: from, ToCurrency: to}
+	err = global.Db.Transaction(func(tx *gorm.DB) error {
+		// 锁住列表行，同一用户的并发追加串行执行，数量上限与重复检查基于最新的条目
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Watchlist{}, list.ID).Error; err != nil {
+			return err
+		}
+		var entries []models.WatchlistEntry
+		if err := tx.Where("watchlist_id = ?", list.ID).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) >= WatchlistMaxEntries {
+			return ErrWatchlistFull
+		}
+
+		for _, e := range entries {
+			if e.FromCurrency == from && e.ToCurrency == to {
+				return ErrWatchlistDuplicate
+			}
+			if e.Position >= entry.Position {
+				entry.Position = e.Position + 1
+			}
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ReorderWatchlist 按 ids 的顺序重排，ids 必须恰好包含列表中的全部条目
+func ReorderWatchlist(userID uint, ids []uint) (*models.Watchlist, error) {
+	list, err := GetWatchlist(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != len(list.Entries) {
+		return nil, ErrWatchlistOrder
+	}
+
+	owned := make(map[uint]bool, len(list.Entries))
+	for _, e := range list.Entries {
+		owned[e.ID] = true
+	}
+	for _, id := range ids {
+		if !owned[id] {
+			return nil, ErrWatchlistOrder
+		}
+		delete(owned, id) // 重复的 id 第二次会找不到
+	}
+
+	err = global.Db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			if err := tx.Model(&models.WatchlistEntry{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetWatchlist(userID)
+}
+
+// RemoveWatchlistEntry 删除自选条目，返回是否存在
+func RemoveWatchlistEntry(userID uint, entryID uint) (bool, error) {
+	list, err := GetWatchlist(userID)
+	if err != nil {
+		return false, err
+	}
+	result := global.Db.Where("id = ? AND watchlist_id = ?", entryID, list.ID).Delete(&models.WatchlistEntry{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// WatchQuote 自选货币对的当前汇率及涨跌
+type WatchQuote struct {
+	EntryID    uint     `json:"entryId"`
+	Pair       string   `json:"pair"`
+	Rate       *float64 `json:"rate"`                 // 当前汇率，数据缺失时为 null
+	Change24h  *float64 `json:"change24h"`            // 相比 24 小时前的快照的涨跌幅 (%)
+	Change7d   *float64 `json:"change7d"`             // 相比 7 天前的快照的涨跌幅 (%)
+	Overridden bool     `json:"overridden,omitempty"` // 当前汇率使用了管理员人工汇率
+}
+
+// WatchlistQuotes 为自选列表一次性计算报价：当前快照、其前 1 天与前 7 天的快照各读取一次
+func WatchlistQuotes(ctx context.Context, entries []models.WatchlistEntry, now time.Time) ([]WatchQuote, error) {
+	quotes := make([]WatchQuote, len(entries))
+	if len(entries) == 0 {
+		return quotes, nil
+	}
+
+	current, err := LoadBaseSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := LoadActiveOverrides(now)
+	if err != nil {
+		return nil, err
+	}
+	// 对比基准按当前快照的日期回推，数据源停更时涨跌幅仍是相邻快照之间的变化
+	day := SnapshotDate(current.Date)
+	if day.IsZero() {
+		day = SnapshotDate(now)
+	}
+	dayAgo, err := LoadSnapshotAsOf(day.AddDate(0, 0, -1))
+	if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+		return nil, err
+	}
+	weekAgo, err := LoadSnapshotAsOf(day.AddDate(0, 0, -7))
+	if err != nil && !errors.Is(err, ErrRatesUnavailable) {
+		return nil, err
+	}
+
+	for i, e := range entries {
+		q := WatchQuote{EntryID: e.ID, Pair: e.FromCurrency + "/" + e.ToCurrency}
+		usdToFrom, usdToTo, overridden, ok := overrides.Legs(current, e.FromCurrency, e.ToCurrency)
+		if ok {
+			rate := usdToTo / usdToFrom
+			q.Rate = &rate
+			q.Overridden = overridden
+			q.Change24h = changePct(rate, dayAgo, e.FromCurrency, e.ToCurrency)
+			q.Change7d = changePct(rate, weekAgo, e.FromCurrency, e.ToCurrency)
+		}
+		quotes[i] = q
+	}
+	return quotes, nil
+}
+
+// changePct 当前汇率相对历史快照中同一货币对的涨跌幅，历史数据缺失时返回 nil
+func changePct(rate float64, past *BaseSnapshot, from, to string) *float64 {
+	if past == nil {
+		return nil
+	}
+	pastRate, ok := pairRate(past, from, to)
+	if !ok {
+		return nil
+	}
+	change := (rate/pastRate - 1) * 100
+	return &change
+}
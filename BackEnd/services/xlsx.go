@@ -0,0 +1,156 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 最小化的 XLSX (SpreadsheetML) 写入器：单个工作表，行数据边写边压缩输出，不依赖第三方库
+
+// 单元格样式，对应 styles.xml 中 cellXfs 的下标
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleDate
+	xlsxStyleNumber
+	xlsxStyleHeader
+)
+
+// Excel 1900 日期系统的零点
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxCell 单元格：Date 非零时为日期，Number 非空时为数字，否则为文本 (Text 为空时留空)
+type xlsxCell struct {
+	Text   string
+	Number *float64
+	Date   time.Time
+	Style  int
+}
+
+type xlsxWriter struct {
+	zw        *zip.Writer
+	sheet     *bufio.Writer
+	sheetName string
+	precision int
+	rows      int
+}
+
+func newXLSXWriter(w io.Writer, sheetName string, columns, precision int) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	part, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(part), sheetName: sheetName, precision: precision}
+
+	x.sheet.WriteString(xml.Header)
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// 冻结表头行
+	x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	fmt.Fprintf(x.sheet, `<cols><col min="1" max="1" width="12" customWidth="1"/><col min="2" max="%d" width="16" customWidth="1"/></cols>`, max(columns, 2))
+	x.sheet.WriteString(`<sheetData>`)
+	return x, nil
+}
+
+// WriteRow 追加一行
+func (x *xlsxWriter) WriteRow(cells []xlsxCell) error {
+	x.rows++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+	for i, c := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(x.rows)
+		switch {
+		case !c.Date.IsZero():
+			y, m, d := c.Date.Date()
+			serial := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(xlsxEpoch).Hours() / 24
+			fmt.Fprintf(x.sheet, `<c r="%s" s="%d"><v>%d</v></c>`, ref, xlsxStyleDate, int(serial))
+		case c.Number != nil:
+			fmt.Fprintf(x.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleNumber, strconv.FormatFloat(*c.Number, 'f', -1, 64))
+		case c.Text != "":
+			fmt.Fprintf(x.sheet, `<c r="%s" s="%d" t="inlineStr"><is><t>`, ref, c.Style)
+			xml.EscapeText(x.sheet, []byte(c.Text))
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Flush 将已压缩的数据推送到底层 Writer
+func (x *xlsxWriter) Flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Flush()
+}
+
+// Close 结束工作表并写入工作簿的其余部分
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+
+	numberFormat := "#,##0"
+	if x.precision > 0 {
+		numberFormat += "." + strings.Repeat("0", x.precision)
+	}
+	var sheetName strings.Builder
+	xml.EscapeText(&sheetName, []byte(x.sheetName))
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + sheetName.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="` + numberFormat + `"/></numFmts>` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="4">` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`</cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, p := range parts {
+		f, err := x.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+p.body); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// xlsxColumn 列下标转列名：0 -> A，26 -> AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}